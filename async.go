@@ -0,0 +1,160 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	overflowBlock      = "block"
+	overflowDropNewest = "drop-newest"
+	overflowDropOldest = "drop-oldest"
+)
+
+// _droppedEntries counts the entries dropped by every async writer and by the
+// buffers of remote sinks.
+var _droppedEntries uint64
+
+// DroppedEntries returns the number of entries dropped so far because the
+// queue of an async logger or the buffer of a remote output path was full.
+func DroppedEntries() uint64 {
+	return atomic.LoadUint64(&_droppedEntries)
+}
+
+// asyncWriter is a WriteSyncer which queues writes in a bounded queue and
+// writes them to the wrapped WriteSyncer from its own goroutine. When the queue
+// is full the overflow policy decides whether Write blocks, or the newest or
+// the oldest entry is dropped.
+type asyncWriter struct {
+	w        zapcore.WriteSyncer
+	overflow string
+	queue    chan []byte
+	flushReq chan chan struct{}
+	done     chan struct{}
+	stopped  chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newAsyncWriter(w zapcore.WriteSyncer, size int, overflow string, interval time.Duration) *asyncWriter {
+	a := &asyncWriter{
+		w:        w,
+		overflow: overflow,
+		queue:    make(chan []byte, size),
+		flushReq: make(chan chan struct{}),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go a.run(interval)
+
+	return a
+}
+
+func (a *asyncWriter) Write(p []byte) (int, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return a.w.Write(p) //nolint: wrapcheck // keep the error of the sink.
+	}
+
+	// the encoder reuses p once Write returns.
+	entry := append([]byte(nil), p...)
+	switch a.overflow {
+	case overflowDropNewest:
+		select {
+		case a.queue <- entry:
+		default:
+			atomic.AddUint64(&_droppedEntries, 1)
+		}
+	case overflowDropOldest:
+		for {
+			select {
+			case a.queue <- entry:
+				return len(p), nil
+			default:
+			}
+			select {
+			case <-a.queue:
+				atomic.AddUint64(&_droppedEntries, 1)
+			default:
+			}
+		}
+	default:
+		a.queue <- entry
+	}
+
+	return len(p), nil
+}
+
+// Sync writes every queued entry and syncs the wrapped WriteSyncer.
+func (a *asyncWriter) Sync() error {
+	a.mu.RLock()
+	closed := a.closed
+	if !closed {
+		ack := make(chan struct{})
+		a.flushReq <- ack
+		<-ack
+	}
+	a.mu.RUnlock()
+
+	return a.w.Sync() //nolint: wrapcheck // keep the error of the sink.
+}
+
+// Close writes every queued entry and stops the goroutine, later writes go
+// straight to the wrapped WriteSyncer.
+func (a *asyncWriter) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+
+		return nil
+	}
+	a.closed = true
+	close(a.done)
+	a.mu.Unlock()
+	<-a.stopped
+
+	return a.w.Sync() //nolint: wrapcheck // keep the error of the sink.
+}
+
+func (a *asyncWriter) run(interval time.Duration) {
+	defer close(a.stopped)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case entry := <-a.queue:
+			_, _ = a.w.Write(entry)
+		case <-tick:
+			_ = a.w.Sync()
+		case ack := <-a.flushReq:
+			a.drain()
+			close(ack)
+		case <-a.done:
+			a.drain()
+
+			return
+		}
+	}
+}
+
+// drain writes the entries queued so far.
+func (a *asyncWriter) drain() {
+	for {
+		select {
+		case entry := <-a.queue:
+			_, _ = a.w.Write(entry)
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// gateWriter blocks the first write until the gate is opened.
+type gateWriter struct {
+	started chan struct{}
+	gate    chan struct{}
+	once    sync.Once
+
+	mu      sync.Mutex
+	entries []string
+}
+
+func newGateWriter() *gateWriter {
+	return &gateWriter{started: make(chan struct{}), gate: make(chan struct{})}
+}
+
+func (w *gateWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() {
+		close(w.started)
+		<-w.gate
+	})
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.entries = append(w.entries, string(p))
+
+	return len(p), nil
+}
+
+func (w *gateWriter) Sync() error { return nil }
+
+func Test_asyncWriter_Overflow(t *testing.T) {
+	tests := map[string][]string{
+		overflowDropNewest: {"a", "b", "c"},
+		overflowDropOldest: {"a", "c", "d"},
+	}
+	for overflow, expected := range tests {
+		w := newGateWriter()
+		a := newAsyncWriter(w, 2, overflow, 0)
+		dropped := DroppedEntries()
+
+		_, _ = a.Write([]byte("a"))
+		<-w.started
+		for _, entry := range []string{"b", "c", "d"} {
+			n, err := a.Write([]byte(entry))
+			assert.Nil(t, err, overflow)
+			assert.Equal(t, 1, n, overflow)
+		}
+		assert.Equal(t, dropped+1, DroppedEntries(), overflow)
+
+		close(w.gate)
+		assert.Nil(t, a.Sync(), overflow)
+		assert.Equal(t, expected, w.entries, overflow)
+		assert.Nil(t, a.Close(), overflow)
+	}
+}
+
+func Test_asyncWriter_Block(t *testing.T) {
+	w := newGateWriter()
+	a := newAsyncWriter(w, 1, overflowBlock, 0)
+
+	_, _ = a.Write([]byte("a"))
+	<-w.started
+	_, _ = a.Write([]byte("b"))
+
+	var written int32
+	go func() {
+		_, _ = a.Write([]byte("c"))
+		atomic.StoreInt32(&written, 1)
+	}()
+	assert.Never(t, func() bool { return atomic.LoadInt32(&written) == 1 }, 50*time.Millisecond, 10*time.Millisecond)
+
+	close(w.gate)
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&written) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Nil(t, a.Close())
+	assert.Equal(t, []string{"a", "b", "c"}, w.entries)
+
+	// writes after close go straight to the wrapped writer.
+	_, _ = a.Write([]byte("d"))
+	assert.Equal(t, []string{"a", "b", "c", "d"}, w.entries)
+}
@@ -0,0 +1,398 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	minSendBackoff = 100 * time.Millisecond
+	maxSendBackoff = 30 * time.Second
+)
+
+// entryBuffer holds the entries of a remote sink until they are delivered.
+// Entries are numbered in the order they are pushed. Its methods are called
+// with the lock of the bufferedWriter held.
+type entryBuffer interface {
+	push(p []byte) error
+	// peek returns up to max of the oldest entries and the number of the
+	// first one. The entries are bounded by maxBytes, unless the first one is
+	// larger, when it is positive.
+	peek(max, maxBytes int) (uint64, [][]byte)
+	// pop removes the entries numbered below next.
+	pop(next uint64)
+	empty() bool
+	// durable tells whether the entries survive a restart of the process.
+	durable() bool
+	sync() error
+	close() error
+}
+
+// permanentError marks an error of send which retrying does not fix, the
+// batch is dropped.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// retryError marks an error of send which delivered the batch but its retry
+// entries. Only they are sent again, up to the retries of the policy.
+type retryError struct {
+	err   error
+	retry [][]byte
+}
+
+func (e *retryError) Error() string { return e.err.Error() }
+
+func (e *retryError) Unwrap() error { return e.err }
+
+// batchPolicy groups the buffered entries into the batches given to send. A
+// batch is sent once it holds entries or bytes, or wait after its first entry
+// was buffered. Zero fields are not limits.
+type batchPolicy struct {
+	entries int
+	bytes   int
+	wait    time.Duration
+	// retries bounds how many times the entries of a retryError are sent
+	// again before they are rejected, negative retries are unbounded.
+	retries int
+	// reject takes the entries given up after a permanentError or their
+	// retries, they are counted as dropped unless it returns nil.
+	reject func(entries [][]byte, err error) error
+	// interrupt is called by Close once the writer is stopping, to abort a
+	// send blocked on the remote end.
+	interrupt func()
+}
+
+// pendingBatch holds the entries of a batch which are still to send.
+type pendingBatch struct {
+	entries [][]byte
+	retries int
+}
+
+// bufferedWriter is a WriteSyncer which buffers the writes and sends them in
+// order and in batches from its own goroutine, retrying with an exponential
+// backoff while send fails. A batch is sent again as it is, or only its retry
+// entries after a retryError.
+type bufferedWriter struct {
+	buffer  entryBuffer
+	send    func(batch [][]byte) error
+	policy  batchPolicy
+	timeout time.Duration
+	wake    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+
+	mu      sync.Mutex
+	waiters []chan struct{}
+	closed  bool
+}
+
+func newBufferedWriter(
+	buffer entryBuffer,
+	send func(batch [][]byte) error,
+	policy batchPolicy,
+	timeout time.Duration,
+) *bufferedWriter {
+	if policy.entries <= 0 {
+		policy.entries = math.MaxInt32
+	}
+	w := &bufferedWriter{
+		buffer:  buffer,
+		send:    send,
+		policy:  policy,
+		timeout: timeout,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go w.run()
+
+	return w
+}
+
+// sendEach sends every entry of a batch on its own.
+func sendEach(send func(p []byte) error) func(batch [][]byte) error {
+	return func(batch [][]byte) error {
+		for _, p := range batch {
+			if err := send(p); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+func (w *bufferedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+
+		return 0, errors.New("write to closed remote output")
+	}
+	err := w.buffer.push(p)
+	w.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	w.notify()
+
+	return len(p), nil
+}
+
+func (w *bufferedWriter) notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Sync sends the buffered entries without waiting for full batches, and waits
+// until they are delivered or the timeout expires, a zero timeout does not
+// wait. Entries of a durable buffer which are not delivered in time are synced
+// to it instead.
+func (w *bufferedWriter) Sync() error {
+	if w.wait() {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch {
+	case w.buffer.durable():
+		return w.buffer.sync()
+	case w.timeout == 0:
+		return nil
+	default:
+		return fmt.Errorf("flush of remote output timed out after %v", w.timeout)
+	}
+}
+
+// wait tells whether the buffered entries were delivered within the timeout.
+func (w *bufferedWriter) wait() bool {
+	w.mu.Lock()
+	if w.buffer.empty() {
+		w.mu.Unlock()
+
+		return true
+	}
+	if w.timeout == 0 {
+		w.mu.Unlock()
+
+		return false
+	}
+	delivered := make(chan struct{})
+	w.waiters = append(w.waiters, delivered)
+	w.mu.Unlock()
+	w.notify()
+
+	timer := time.NewTimer(w.timeout)
+	defer timer.Stop()
+	select {
+	case <-delivered:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Close tries to deliver the buffered entries within the timeout, stops the
+// writer and closes its buffer.
+func (w *bufferedWriter) Close() error {
+	err := w.Sync()
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+	close(w.done)
+	if w.policy.interrupt != nil {
+		w.policy.interrupt()
+	}
+	<-w.stopped
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if cerr := w.buffer.close(); cerr != nil && err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func (w *bufferedWriter) run() {
+	defer close(w.stopped)
+
+	backoff := minSendBackoff
+	var first time.Time
+	for {
+		w.mu.Lock()
+		next, batch := w.buffer.peek(w.policy.entries, w.policy.bytes)
+		flush := len(w.waiters) != 0
+		w.mu.Unlock()
+		if len(batch) == 0 {
+			first = time.Time{}
+			select {
+			case <-w.wake:
+				continue
+			case <-w.done:
+				return
+			}
+		}
+
+		if first.IsZero() {
+			first = time.Now()
+		}
+		if wait := w.policy.wait - time.Since(first); !flush && wait > 0 && !w.full(batch) {
+			timer := time.NewTimer(wait)
+			select {
+			case <-w.wake:
+			case <-timer.C:
+			case <-w.done:
+				timer.Stop()
+
+				return
+			}
+			timer.Stop()
+
+			continue
+		}
+
+		pending := &pendingBatch{entries: batch}
+		for !w.sendPending(pending) {
+			select {
+			case <-time.After(backoff):
+			case <-w.done:
+				return
+			}
+			if backoff *= 2; backoff > maxSendBackoff {
+				backoff = maxSendBackoff
+			}
+		}
+		backoff = minSendBackoff
+		first = time.Time{}
+
+		w.mu.Lock()
+		w.buffer.pop(next + uint64(len(batch)))
+		if w.buffer.empty() {
+			for _, ch := range w.waiters {
+				close(ch)
+			}
+			w.waiters = nil
+		}
+		w.mu.Unlock()
+	}
+}
+
+// sendPending sends the entries of batch, it tells whether the batch is done
+// with, delivered or rejected, or has to be sent again after a backoff.
+func (w *bufferedWriter) sendPending(batch *pendingBatch) bool {
+	err := w.send(batch.entries)
+	var permanent *permanentError
+	var partial *retryError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &permanent):
+		w.reject(batch.entries, err)
+
+		return true
+	case errors.As(err, &partial) && w.policy.retries >= 0 && batch.retries >= w.policy.retries:
+		w.reject(partial.retry, err)
+
+		return true
+	case errors.As(err, &partial):
+		batch.entries = partial.retry
+		batch.retries++
+	}
+
+	return false
+}
+
+// reject hands the entries given up after err to the policy, they are counted
+// as dropped unless it takes them.
+func (w *bufferedWriter) reject(entries [][]byte, err error) {
+	if w.policy.reject == nil || w.policy.reject(entries, err) != nil {
+		atomic.AddUint64(&_droppedEntries, uint64(len(entries)))
+	}
+}
+
+// full tells whether batch reached the limits of the policy.
+func (w *bufferedWriter) full(batch [][]byte) bool {
+	if len(batch) >= w.policy.entries {
+		return true
+	}
+	if w.policy.bytes <= 0 {
+		return false
+	}
+	size := 0
+	for _, p := range batch {
+		size += len(p)
+	}
+
+	return size >= w.policy.bytes
+}
+
+// memoryBuffer is an entryBuffer bounded in size, the oldest entries are
+// dropped beyond it.
+type memoryBuffer struct {
+	maxSize int
+	entries [][]byte
+	first   uint64
+	size    int
+}
+
+func (b *memoryBuffer) push(p []byte) error {
+	entry := append([]byte(nil), p...)
+	b.entries = append(b.entries, entry)
+	b.size += len(entry)
+	for b.size > b.maxSize && len(b.entries) > 1 {
+		b.pop(b.first + 1)
+		atomic.AddUint64(&_droppedEntries, 1)
+	}
+
+	return nil
+}
+
+func (b *memoryBuffer) peek(max, maxBytes int) (uint64, [][]byte) {
+	n, size := 0, 0
+	for n < len(b.entries) && n < max {
+		size += len(b.entries[n])
+		if maxBytes > 0 && n > 0 && size > maxBytes {
+			break
+		}
+		n++
+	}
+
+	// a copy, since push drops entries while the batch is sent.
+	return b.first, append([][]byte(nil), b.entries[:n]...)
+}
+
+func (b *memoryBuffer) pop(next uint64) {
+	for len(b.entries) != 0 && b.first < next {
+		b.size -= len(b.entries[0])
+		b.entries[0] = nil
+		b.entries = b.entries[1:]
+		b.first++
+	}
+}
+
+func (b *memoryBuffer) empty() bool { return len(b.entries) == 0 }
+
+func (b *memoryBuffer) durable() bool { return false }
+
+func (b *memoryBuffer) sync() error { return nil }
+
+func (b *memoryBuffer) close() error { return nil }
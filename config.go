@@ -0,0 +1,188 @@
+package log
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/pflag"
+	"go.uber.org/multierr"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadOptionsFromFile reads Options from a yaml, json or toml file, the parser
+// is picked by the extension of path. Fields missing from the file keep the
+// defaults of NewOptions. All validation errors are returned at once, they can
+// be split with multierr.Errors.
+func LoadOptionsFromFile(path string) (*Options, error) {
+	opts := NewOptions()
+	if err := opts.loadFile(path); err != nil {
+		return nil, err
+	}
+	if errs := opts.Validate(); len(errs) != 0 {
+		return nil, multierr.Combine(errs...)
+	}
+
+	return opts, nil
+}
+
+// loadFile overrides the fields of o with the ones set in the file at path.
+func (o *Options) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read log options: %w", err)
+	}
+	data, err = optionsFileToJSON(path, data)
+	if err != nil {
+		return fmt.Errorf("parse log options %s: %w", path, err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(o); err != nil {
+		return fmt.Errorf("decode log options %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// optionsFileToJSON converts the content of an options file to json, so that
+// the json tags of Options are the single source of field names.
+func optionsFileToJSON(path string, data []byte) ([]byte, error) {
+	var raw map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		return data, nil
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("yaml: %w", err)
+		}
+	case ".toml":
+		if err := toml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("toml: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported file extension %q, use yaml, json or toml", ext)
+	}
+
+	res, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("json: %w", err)
+	}
+
+	return res, nil
+}
+
+// ApplyFlags overrides the fields of o with the log flags explicitly set on fs,
+// so that flags take precedence over a file loaded by LoadOptionsFromFile. fs
+// must hold flags registered by AddFlags, they may be bound to another Options.
+// The merged options are validated, all errors are returned at once.
+func (o *Options) ApplyFlags(fs *pflag.FlagSet) error {
+	own := pflag.NewFlagSet("log", pflag.ContinueOnError)
+	o.AddFlags(own)
+
+	var errs []error
+	fs.Visit(func(f *pflag.Flag) {
+		target := own.Lookup(f.Name)
+		if target == nil {
+			return
+		}
+		if err := copyFlagValue(target.Value, f.Value); err != nil {
+			errs = append(errs, fmt.Errorf("flag --%s: %w", f.Name, err))
+		}
+	})
+	if len(errs) != 0 {
+		return multierr.Combine(errs...)
+	}
+
+	return multierr.Combine(o.Validate()...)
+}
+
+func copyFlagValue(dst, src pflag.Value) error {
+	if s, ok := src.(pflag.SliceValue); ok {
+		if d, ok := dst.(pflag.SliceValue); ok {
+			return d.Replace(s.GetSlice())
+		}
+	}
+
+	value := src.String()
+	if src.Type() == "stringToString" {
+		// String of a map flag is wrapped with brackets which Set does not
+		// accept, an empty map clears the one of dst.
+		value = strings.Trim(value, "[]")
+	}
+
+	return dst.Set(value)
+}
+
+// mapValue is a key=value pairs flag like the stringToString flags of pflag,
+// except that an empty value clears the map, e.g. --log.levels= .
+type mapValue struct {
+	value   *map[string]string
+	changed bool
+}
+
+func newMapValue(p *map[string]string) *mapValue {
+	return &mapValue{value: p}
+}
+
+// Set replaces the map on the first call and merges into it on later ones.
+func (m *mapValue) Set(val string) error {
+	var pairs []string
+	switch strings.Count(val, "=") {
+	case 0:
+		if val != "" {
+			return fmt.Errorf("%s must be formatted as key=value", val)
+		}
+	case 1:
+		pairs = []string{strings.Trim(val, `"`)}
+	default:
+		var err error
+		if pairs, err = csv.NewReader(strings.NewReader(val)).Read(); err != nil {
+			return err //nolint: wrapcheck // keep the error of the reader.
+		}
+	}
+
+	res := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("%s must be formatted as key=value", pair)
+		}
+		res[kv[0]] = kv[1]
+	}
+	switch {
+	case !m.changed || len(res) == 0:
+		*m.value = res
+	default:
+		for k, v := range res {
+			(*m.value)[k] = v
+		}
+	}
+	m.changed = true
+
+	return nil
+}
+
+func (m *mapValue) Type() string { return "stringToString" }
+
+func (m *mapValue) String() string {
+	pairs := make([]string, 0, len(*m.value))
+	for k, v := range *m.value {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write(pairs)
+	w.Flush()
+
+	return "[" + strings.TrimSpace(buf.String()) + "]"
+}
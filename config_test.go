@@ -0,0 +1,103 @@
+package log_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/multierr"
+
+	"github.com/huanghe314/log"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func Test_LoadOptionsFromFile(t *testing.T) {
+	files := map[string]string{
+		"log.yaml": `
+level: debug
+format: json
+output-paths: [stdout, app.log]
+levels:
+  storage: warn
+`,
+		"log.json": `{"level": "debug", "format": "json", "output-paths": ["stdout", "app.log"], "levels": {"storage": "warn"}}`,
+		"log.toml": `
+level = "debug"
+format = "json"
+output-paths = ["stdout", "app.log"]
+
+[levels]
+storage = "warn"
+`,
+	}
+
+	for name, content := range files {
+		opts, err := log.LoadOptionsFromFile(writeFile(t, name, content))
+		assert.Nil(t, err, name)
+		assert.Equal(t, "debug", opts.Level, name)
+		assert.Equal(t, "json", opts.Format, name)
+		assert.Equal(t, []string{"stdout", "app.log"}, opts.OutputPaths, name)
+		assert.Equal(t, map[string]string{"storage": "warn"}, opts.Levels, name)
+		// defaults are kept for the fields missing from the file.
+		assert.Equal(t, []string{"stderr"}, opts.ErrorOutputPaths, name)
+	}
+}
+
+func Test_LoadOptionsFromFile_Errors(t *testing.T) {
+	_, err := log.LoadOptionsFromFile(writeFile(t, "log.yaml", "level: loud\nformat: xml\n"))
+	assert.NotNil(t, err)
+	assert.Len(t, multierr.Errors(err), 2)
+
+	_, err = log.LoadOptionsFromFile(writeFile(t, "log.yaml", "colour: true\n"))
+	assert.NotNil(t, err)
+
+	_, err = log.LoadOptionsFromFile(writeFile(t, "log.ini", "level=debug\n"))
+	assert.NotNil(t, err)
+}
+
+func Test_Options_ApplyFlags(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flagOpts := log.NewOptions()
+	flagOpts.AddFlags(fs)
+	err := fs.Parse([]string{"--log.format=console", "--log.error-output-paths=err.log", "--log.levels=a=warn,b.c=error"})
+	assert.Nil(t, err)
+
+	opts, err := log.LoadOptionsFromFile(writeFile(t, "log.yaml", "level: debug\nformat: json\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, opts.ApplyFlags(fs))
+
+	// defaults < file < flags
+	assert.Equal(t, "debug", opts.Level)
+	assert.Equal(t, "console", opts.Format)
+	assert.Equal(t, []string{"stdout"}, opts.OutputPaths)
+	assert.Equal(t, []string{"err.log"}, opts.ErrorOutputPaths)
+	assert.Equal(t, map[string]string{"a": "warn", "b.c": "error"}, opts.Levels)
+}
+
+func Test_Options_ApplyFlags_Merged(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	log.NewOptions().AddFlags(fs)
+	assert.Nil(t, fs.Parse([]string{"--log.levels="}))
+
+	// an empty map flag clears the map of the file.
+	opts, err := log.LoadOptionsFromFile(writeFile(t, "log.yaml", "levels: {db: warn}\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, opts.ApplyFlags(fs))
+	assert.Empty(t, opts.Levels)
+
+	// the merged options are validated.
+	fs = pflag.NewFlagSet("test", pflag.ContinueOnError)
+	log.NewOptions().AddFlags(fs)
+	assert.Nil(t, fs.Parse([]string{"--log.format=xml"}))
+	assert.NotNil(t, opts.ApplyFlags(fs))
+}
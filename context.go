@@ -20,27 +20,56 @@ package log
 
 import (
 	"context"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 type key int
 
 const (
 	logContextKey key = iota
+	spanContextKey
+)
+
+// The keys of the fields holding the ids of the span of a context.
+const (
+	traceIDKey = "trace_id"
+	spanIDKey  = "span_id"
 )
 
+type spanContext struct {
+	traceID, spanID string
+}
+
 // WithContext returns a copy of context in which the log value is set.
 func (l *logger) WithContext(ctx context.Context) context.Context {
 	return context.WithValue(ctx, logContextKey, l)
 }
 
-// FromContext returns the value of the log key on the ctx.
+// WithSpanContext returns a copy of ctx carrying the hex encoded ids of a
+// trace and a span, which the loggers returned by FromContext add to their
+// entries when ctx holds no valid OpenTelemetry span context. The otlp output
+// paths send them as the ids of the log records.
+func WithSpanContext(ctx context.Context, traceID, spanID string) context.Context {
+	return context.WithValue(ctx, spanContextKey, spanContext{traceID: traceID, spanID: spanID})
+}
+
+// FromContext returns the value of the log key on the ctx, with the ids of
+// the OpenTelemetry span of ctx, or else the ids set by WithSpanContext.
 func FromContext(ctx context.Context) Logger {
-	if ctx != nil {
-		logger := ctx.Value(logContextKey)
-		if logger != nil {
-			return logger.(Logger)
-		}
+	if ctx == nil {
+		return WithName("Unknown-Context")
+	}
+	logger, ok := ctx.Value(logContextKey).(Logger)
+	if !ok {
+		logger = WithName("Unknown-Context")
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		return logger.WithValues(traceIDKey, span.TraceID().String(), spanIDKey, span.SpanID().String())
+	}
+	if span, ok := ctx.Value(spanContextKey).(spanContext); ok {
+		logger = logger.WithValues(traceIDKey, span.traceID, spanIDKey, span.spanID)
 	}
 
-	return WithName("Unknown-Context")
+	return logger
 }
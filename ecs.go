@@ -0,0 +1,89 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	ecsFormat  = "ecs"
+	ecsVersion = "8.11.0"
+
+	ecsErrorKey = "error"
+)
+
+// ecsEncoderConfig remaps the keys of cfg to the Elastic Common Schema. The
+// caller is encoded by the ecsEncoder.
+func ecsEncoderConfig(cfg zapcore.EncoderConfig) zapcore.EncoderConfig {
+	cfg.TimeKey = "@timestamp"
+	cfg.LevelKey = "log.level"
+	cfg.NameKey = "log.logger"
+	cfg.CallerKey = ""
+	cfg.MessageKey = "message"
+	cfg.StacktraceKey = "error.stack_trace"
+	cfg.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+	cfg.EncodeLevel = zapcore.LowercaseLevelEncoder
+
+	return cfg
+}
+
+// ecsFields returns the fields every entry of the ecs format holds.
+func ecsFields(name string) map[string]interface{} {
+	fields := map[string]interface{}{"ecs.version": ecsVersion}
+	if name != "" {
+		fields["service.name"] = name
+	}
+
+	return fields
+}
+
+// ecsEncoder is a JSON encoder writing the Elastic Common Schema. The error
+// field of an entry is turned into error.message and error.type, and its
+// stack trace when it has one replaces the one of the entry.
+type ecsEncoder struct {
+	zapcore.Encoder
+}
+
+func newECSEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return &ecsEncoder{Encoder: zapcore.NewJSONEncoder(ecsEncoderConfig(cfg))}
+}
+
+func (enc *ecsEncoder) Clone() zapcore.Encoder {
+	return &ecsEncoder{Encoder: enc.Encoder.Clone()}
+}
+
+func (enc *ecsEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	ecs := make([]zapcore.Field, 0, len(fields)+3)
+	for _, f := range fields {
+		err, ok := f.Interface.(error)
+		if f.Type != zapcore.ErrorType || f.Key != ecsErrorKey || !ok {
+			ecs = append(ecs, f)
+
+			continue
+		}
+		msg := err.Error()
+		ecs = append(ecs, zap.String("error.message", msg), zap.String("error.type", fmt.Sprintf("%T", err)))
+		if _, ok := err.(fmt.Formatter); ok {
+			if verbose := fmt.Sprintf("%+v", err); verbose != msg {
+				ent.Stack = verbose
+			}
+		}
+	}
+	if ent.Caller.Defined {
+		// the trimmed path is the package and the file followed by the line.
+		file := ent.Caller.TrimmedPath()
+		if i := strings.LastIndexByte(file, ':'); i >= 0 {
+			file = file[:i]
+		}
+		ecs = append(ecs, zap.String("log.origin.file.name", file), zap.Int("log.origin.file.line", ent.Caller.Line))
+		if ent.Caller.Function != "" {
+			ecs = append(ecs, zap.String("log.origin.function", ent.Caller.Function))
+		}
+	}
+
+	return enc.Encoder.EncodeEntry(ent, ecs) //nolint: wrapcheck // keep the error of the encoder.
+}
@@ -0,0 +1,80 @@
+package log_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/huanghe314/log"
+)
+
+// stackError is an error formatting its stack trace with %+v.
+type stackError struct{ msg string }
+
+func (e *stackError) Error() string { return e.msg }
+
+func (e *stackError) Format(s fmt.State, verb rune) {
+	_, _ = io.WriteString(s, e.msg)
+	if verb == 'v' && s.Flag('+') {
+		_, _ = io.WriteString(s, "\nmain.run\n\t/src/main.go:12")
+	}
+}
+
+func Test_ECS(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	opts := log.NewOptions()
+	opts.Name = "checkout"
+	opts.Format = "ecs"
+	opts.EnableCaller = true
+	opts.OutputPaths = []string{path}
+	opts.ErrorOutputPaths = []string{path}
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+
+	logger.WithName("payments").Infow("charged", "amount", 12)
+	logger.Error("declined", log.Err(&stackError{msg: "card expired"}))
+	logger.Info("retrying", log.Err(errors.New("timeout")))
+	assert.Nil(t, closeFunc())
+
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 3)
+	docs := make([]map[string]interface{}, len(lines))
+	for i, line := range lines {
+		assert.Nil(t, json.Unmarshal([]byte(line), &docs[i]), line)
+	}
+
+	info := docs[0]
+	ts, err := time.Parse(time.RFC3339Nano, info["@timestamp"].(string))
+	assert.Nil(t, err)
+	assert.WithinDuration(t, time.Now(), ts, time.Minute)
+	assert.Equal(t, "info", info["log.level"])
+	assert.Equal(t, "charged", info["message"])
+	assert.Equal(t, "payments", info["log.logger"])
+	assert.Equal(t, "checkout", info["service.name"])
+	assert.NotEmpty(t, info["ecs.version"])
+	assert.Equal(t, "ecs_test.go", filepath.Base(info["log.origin.file.name"].(string)))
+	assert.NotZero(t, info["log.origin.file.line"])
+	assert.Equal(t, float64(12), info["amount"])
+	assert.NotContains(t, info, "caller")
+
+	failed := docs[1]
+	assert.Equal(t, "error", failed["log.level"])
+	assert.Equal(t, "card expired", failed["error.message"])
+	assert.Equal(t, "*log_test.stackError", failed["error.type"])
+	assert.Equal(t, "card expired\nmain.run\n\t/src/main.go:12", failed["error.stack_trace"])
+	assert.NotContains(t, failed, "error")
+
+	assert.Equal(t, "timeout", docs[2]["error.message"])
+	assert.Equal(t, "*errors.errorString", docs[2]["error.type"])
+	assert.NotContains(t, docs[2], "error.stack_trace")
+}
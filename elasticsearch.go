@@ -0,0 +1,170 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	esBulkPath = "/_bulk"
+	// esItemRetries bounds how many times the items of a bulk request failing
+	// with 429 or 5xx are sent again before they are dropped.
+	esItemRetries = 5
+)
+
+// nolint: gochecknoinits // register the elasticsearch output path schemes.
+func init() {
+	scheme := sinkScheme{open: openESSink, config: esConfig, buffered: true}
+	_sinkSchemes["es+http"] = scheme
+	_sinkSchemes["es+https"] = scheme
+}
+
+// ElasticsearchOptions configures the es+http://host:9200 and es+https://
+// output paths, which index the entries encoded as JSON, or as ECS with the
+// ecs format, through the bulk API of Elasticsearch or OpenSearch. Credentials
+// of the url are sent with basic auth unless APIKey or APIKeyFile is set.
+type ElasticsearchOptions struct {
+	// Index is the index name pattern, %Y, %m, %d and %H are replaced by the
+	// UTC time of the entry. It defaults to the name of the logger, or logs,
+	// followed by -%Y.%m.%d.
+	Index  string `json:"index"        mapstructure:"index"`
+	APIKey string `json:"api-key"      mapstructure:"api-key"`
+	// APIKeyFile is read again whenever it changes.
+	APIKeyFile string       `json:"api-key-file" mapstructure:"api-key-file"`
+	Batch      BatchOptions `json:"batch"        mapstructure:"batch"`
+}
+
+func (o ElasticsearchOptions) validate() []error {
+	var errs []error
+	if o.APIKey != "" && o.APIKeyFile != "" {
+		errs = append(errs, errors.New("both api key and api key file of elasticsearch are set"))
+	}
+
+	return append(errs, o.Batch.validate("elasticsearch")...)
+}
+
+type esSinkConfig struct {
+	es     ElasticsearchOptions
+	stream streamSinkConfig
+}
+
+func esConfig(opts *Options) interface{} {
+	cfg := esSinkConfig{es: opts.Elasticsearch, stream: streamConfig(opts).(streamSinkConfig)}
+	if cfg.es.Index == "" {
+		name := opts.Name
+		if name == "" {
+			name = "logs"
+		}
+		cfg.es.Index = name + "-%Y.%m.%d"
+	}
+
+	return cfg
+}
+
+type esBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+func openESSink(u *url.URL, opts *Options) (*sink, error) {
+	target := httpTarget(u, esBulkPath)
+	client, err := newHTTPClient(target, opts)
+	if err != nil {
+		return nil, err
+	}
+	cfg, _ := esConfig(opts).(esSinkConfig)
+	apiKey := &tokenFile{path: cfg.es.APIKeyFile}
+	encoder := jsonEncoderFromOpts(opts)
+
+	// a buffered entry is the name of its index and its document separated by
+	// a newline.
+	encode := func(_ zapcore.Encoder, ent zapcore.Entry, fields []zapcore.Field) ([]byte, error) {
+		buf, err := encoder.EncodeEntry(ent, fields)
+		if err != nil {
+			return nil, err //nolint: wrapcheck // keep the error of the encoder.
+		}
+		defer buf.Free()
+		t := ent.Time.UTC()
+		index := expandPattern(cfg.es.Index, func(verb byte) string {
+			return _patternVerbs[verb](t)
+		})
+
+		return append([]byte(index+"\n"), bytes.TrimRight(buf.Bytes(), "\n")...), nil
+	}
+
+	// the items failing with 429 or 5xx are sent again alone.
+	send := func(batch [][]byte) error {
+		var body bytes.Buffer
+		for _, record := range batch {
+			index, doc, _ := bytes.Cut(record, []byte("\n"))
+			action, _ := json.Marshal(map[string]map[string]string{"index": {"_index": string(index)}})
+			body.Write(action)
+			body.WriteByte('\n')
+			body.Write(doc)
+			body.WriteByte('\n')
+		}
+		req, err := http.NewRequest(http.MethodPost, target.String(), &body)
+		if err != nil {
+			return &permanentError{err: fmt.Errorf("build bulk request: %w", err)}
+		}
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		switch {
+		case cfg.es.APIKeyFile != "":
+			key, err := apiKey.get()
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Authorization", "ApiKey "+key)
+		case cfg.es.APIKey != "":
+			req.Header.Set("Authorization", "ApiKey "+cfg.es.APIKey)
+		case u.User != nil:
+			password, _ := u.User.Password()
+			req.SetBasicAuth(u.User.Username(), password)
+		}
+		data, err := doRequest(client, req)
+		if err != nil {
+			return err
+		}
+
+		var resp esBulkResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return &permanentError{err: fmt.Errorf("decode bulk response: %w", err)}
+		}
+		if !resp.Errors {
+			return nil
+		}
+		var retry [][]byte
+		for i, item := range resp.Items {
+			for _, result := range item {
+				switch {
+				case i >= len(batch) || result.Status < 300:
+				case result.Status == http.StatusTooManyRequests || result.Status >= 500:
+					retry = append(retry, batch[i])
+				default:
+					atomic.AddUint64(&_droppedEntries, 1)
+				}
+			}
+		}
+
+		if len(retry) == 0 {
+			return nil
+		}
+
+		return &retryError{err: fmt.Errorf("%d items of bulk request failed", len(retry)), retry: retry}
+	}
+
+	policy := cfg.es.Batch.policy()
+	policy.retries = esItemRetries
+
+	return newBatchSink(u, opts, policy, encode, send)
+}
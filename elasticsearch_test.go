@@ -0,0 +1,158 @@
+package log_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/huanghe314/log"
+)
+
+type bulkItem struct {
+	Index string
+	Doc   map[string]interface{}
+}
+
+// bulkServer records the items of the bulk requests it receives, failing the
+// items whose message is listed in fail once, or always when keep is set,
+// with the given status.
+type bulkServer struct {
+	t    *testing.T
+	mu   sync.Mutex
+	fail map[string]int
+	keep bool
+	auth []string
+	reqs [][]bulkItem
+}
+
+func (s *bulkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	assert.Equal(s.t, "/_bulk", r.URL.Path)
+	assert.Equal(s.t, "application/x-ndjson", r.Header.Get("Content-Type"))
+	s.auth = append(s.auth, r.Header.Get("Authorization"))
+
+	var items []bulkItem
+	var results []string
+	failed := false
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var action map[string]map[string]string
+		assert.Nil(s.t, json.Unmarshal(scanner.Bytes(), &action))
+		assert.True(s.t, scanner.Scan(), "missing document after action")
+		var doc map[string]interface{}
+		assert.Nil(s.t, json.Unmarshal(scanner.Bytes(), &doc))
+		items = append(items, bulkItem{Index: action["index"]["_index"], Doc: doc})
+
+		status := http.StatusCreated
+		if code, ok := s.fail[doc["msg"].(string)]; ok {
+			status, failed = code, true
+			if !s.keep {
+				delete(s.fail, doc["msg"].(string))
+			}
+		}
+		results = append(results, fmt.Sprintf(`{"index":{"status":%d}}`, status))
+	}
+	s.reqs = append(s.reqs, items)
+	fmt.Fprintf(w, `{"errors":%t,"items":[%s]}`, failed, strings.Join(results, ","))
+}
+
+func Test_Elasticsearch(t *testing.T) {
+	server := &bulkServer{t: t, fail: map[string]int{"second": http.StatusTooManyRequests, "third": http.StatusBadRequest}}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	opts := log.NewOptions()
+	opts.Name = "app"
+	opts.OutputPaths = []string{strings.Replace(srv.URL, "http://", "es+http://elastic:secret@", 1)}
+	opts.Elasticsearch.Batch.MaxEntries = 3
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	dropped := log.DroppedEntries()
+	logger.Infow("first", "user", "alice")
+	logger.Info("second")
+	logger.Info("third")
+	logger.Flush()
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	// the bulk request is full with 3 entries, only the item failing with 429
+	// is sent again.
+	assert.Len(t, server.reqs, 2)
+	assert.Len(t, server.reqs[0], 3)
+	index := "app-" + time.Now().UTC().Format("2006.01.02")
+	assert.Equal(t, index, server.reqs[0][0].Index)
+	assert.Equal(t, "first", server.reqs[0][0].Doc["msg"])
+	assert.Equal(t, "alice", server.reqs[0][0].Doc["user"])
+	assert.Equal(t, []bulkItem{{Index: index, Doc: server.reqs[0][1].Doc}}, server.reqs[1])
+	assert.Equal(t, "second", server.reqs[1][0].Doc["msg"])
+	assert.Equal(t, uint64(1), log.DroppedEntries()-dropped)
+	assert.Equal(t, "Basic ZWxhc3RpYzpzZWNyZXQ=", server.auth[0])
+}
+
+func Test_Elasticsearch_Close(t *testing.T) {
+	server := &bulkServer{t: t, fail: map[string]int{"stuck": http.StatusServiceUnavailable}, keep: true}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{"es+" + srv.URL}
+	opts.Stream.FlushTimeoutInMS = 100
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+
+	logger.Info("stuck")
+	// the retries of the failing item do not hold up the close.
+	start := time.Now()
+	assert.NotNil(t, closeFunc())
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func Test_Elasticsearch_APIKey(t *testing.T) {
+	server := &bulkServer{t: t}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{"es+" + srv.URL}
+	opts.Elasticsearch.Index = "audit-%Y"
+	opts.Elasticsearch.APIKey = "a2V5"
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	logger.Info("hello")
+	logger.Flush()
+	// the api key is masked when the options are printed.
+	assert.NotContains(t, opts.String(), "a2V5")
+
+	keyFile := filepath.Join(t.TempDir(), "api-key")
+	assert.Nil(t, os.WriteFile(keyFile, []byte("ZmlsZQ==\n"), 0o600))
+	opts.Elasticsearch.APIKey = ""
+	opts.Elasticsearch.APIKeyFile = keyFile
+	logger, closeFunc, err = log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+	logger.Info("from file")
+	logger.Flush()
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, []string{"ApiKey a2V5", "ApiKey ZmlsZQ=="}, server.auth)
+	assert.Equal(t, "audit-"+time.Now().UTC().Format("2006"), server.reqs[0][0].Index)
+
+	opts.Elasticsearch.APIKey = "a2V5"
+	assert.NotEmpty(t, opts.Validate())
+}
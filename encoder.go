@@ -19,13 +19,151 @@
 package log
 
 import (
+	"fmt"
 	"time"
 
 	"go.uber.org/zap/zapcore"
 )
 
-func timeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
-	enc.AppendString(t.Format("2006-01-02 15:04:05.000"))
+const (
+	defaultTimeLayout = "2006-01-02 15:04:05.000"
+
+	timeRFC3339     = "rfc3339"
+	timeRFC3339Nano = "rfc3339nano"
+	timeEpoch       = "epoch"
+	timeEpochMillis = "epoch-millis"
+	timeEpochNanos  = "epoch-nanos"
+
+	durationString  = "string"
+	durationNanos   = "nanos"
+	durationMillis  = "millis"
+	durationSeconds = "seconds"
+
+	levelCapital   = "capital"
+	levelLowercase = "lowercase"
+)
+
+// EncoderOptions configures how the encoders write the entries. An empty key
+// leaves its value out of the entries, NewOptions sets the default keys. The
+// other empty fields keep the defaults.
+type EncoderOptions struct {
+	TimeKey       string `json:"time-key"       mapstructure:"time-key"`
+	LevelKey      string `json:"level-key"      mapstructure:"level-key"`
+	NameKey       string `json:"name-key"       mapstructure:"name-key"`
+	CallerKey     string `json:"caller-key"     mapstructure:"caller-key"`
+	MessageKey    string `json:"message-key"    mapstructure:"message-key"`
+	StacktraceKey string `json:"stacktrace-key" mapstructure:"stacktrace-key"`
+	// TimeFormat is rfc3339, rfc3339nano, epoch, epoch-millis, epoch-nanos or
+	// a time layout such as 2006-01-02 15:04:05.000, the default.
+	TimeFormat string `json:"time-format" mapstructure:"time-format"`
+	// TimeZone is UTC, Local or an IANA time zone name, defaults to Local.
+	TimeZone string `json:"time-zone" mapstructure:"time-zone"`
+	// DurationFormat is string, nanos, millis or seconds, defaults to millis.
+	DurationFormat string `json:"duration-format" mapstructure:"duration-format"`
+	// LevelFormat is capital or lowercase, defaults to capital.
+	LevelFormat string `json:"level-format" mapstructure:"level-format"`
+}
+
+func (o EncoderOptions) validate() []error {
+	var errs []error
+	seen := make(map[string]bool)
+	for _, key := range o.keys() {
+		if key == "" {
+			continue
+		}
+		if seen[key] {
+			errs = append(errs, fmt.Errorf("duplicate encoder key: %q", key))
+		}
+		seen[key] = true
+	}
+	switch o.TimeFormat {
+	case "", timeRFC3339, timeRFC3339Nano, timeEpoch, timeEpochMillis, timeEpochNanos:
+	default:
+		// a layout without any element would write the same text for all times.
+		if time.Unix(0, 0).UTC().Format(o.TimeFormat) == o.TimeFormat {
+			errs = append(errs, fmt.Errorf("not a valid time format: %q", o.TimeFormat))
+		}
+	}
+	if _, err := o.location(); err != nil {
+		errs = append(errs, fmt.Errorf("not a valid time zone: %w", err))
+	}
+	switch o.DurationFormat {
+	case "", durationString, durationNanos, durationMillis, durationSeconds:
+	default:
+		errs = append(errs, fmt.Errorf("not a valid duration format: %q", o.DurationFormat))
+	}
+	if o.LevelFormat != "" && o.LevelFormat != levelCapital && o.LevelFormat != levelLowercase {
+		errs = append(errs, fmt.Errorf("not a valid level format: %q", o.LevelFormat))
+	}
+
+	return errs
+}
+
+// keys returns the time, level, name, caller, message and stacktrace keys.
+func (o EncoderOptions) keys() [6]string {
+	return [6]string{o.TimeKey, o.LevelKey, o.NameKey, o.CallerKey, o.MessageKey, o.StacktraceKey}
+}
+
+func (o EncoderOptions) location() (*time.Location, error) {
+	if o.TimeZone == "" {
+		return time.Local, nil
+	}
+
+	return time.LoadLocation(o.TimeZone) //nolint: wrapcheck // wrapped by the caller.
+}
+
+func (o EncoderOptions) timeEncoder() zapcore.TimeEncoder {
+	switch o.TimeFormat {
+	case timeEpoch:
+		return zapcore.EpochTimeEncoder
+	case timeEpochMillis:
+		return zapcore.EpochMillisTimeEncoder
+	case timeEpochNanos:
+		return zapcore.EpochNanosTimeEncoder
+	}
+	layout := o.TimeFormat
+	switch layout {
+	case "":
+		layout = defaultTimeLayout
+	case timeRFC3339:
+		layout = time.RFC3339
+	case timeRFC3339Nano:
+		layout = time.RFC3339Nano
+	}
+	loc, err := o.location()
+	if err != nil {
+		loc = time.Local
+	}
+
+	return func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+		enc.AppendString(t.In(loc).Format(layout))
+	}
+}
+
+func (o EncoderOptions) durationEncoder() zapcore.DurationEncoder {
+	switch o.DurationFormat {
+	case durationString:
+		return zapcore.StringDurationEncoder
+	case durationNanos:
+		return zapcore.NanosDurationEncoder
+	case durationSeconds:
+		return zapcore.SecondsDurationEncoder
+	default:
+		return milliSecondsDurationEncoder
+	}
+}
+
+func (o EncoderOptions) levelEncoder(color bool) zapcore.LevelEncoder {
+	switch {
+	case o.LevelFormat == levelLowercase && color:
+		return zapcore.LowercaseColorLevelEncoder
+	case o.LevelFormat == levelLowercase:
+		return zapcore.LowercaseLevelEncoder
+	case color:
+		return zapcore.CapitalColorLevelEncoder
+	default:
+		return zapcore.CapitalLevelEncoder
+	}
 }
 
 func milliSecondsDurationEncoder(d time.Duration, enc zapcore.PrimitiveArrayEncoder) {
@@ -0,0 +1,112 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ApplyEnv overrides the fields of o with environment variables. The variable
+// of a field is its json tag upper cased with dashes replaced by underscores
+// and prefixed by prefix, e.g. LOG_OUTPUT_PATHS for the prefix LOG. String
+// slices are comma separated, string maps are comma separated key=value pairs
+// and other structured fields are json encoded. Fields of nested option
+// structs are prefixed by the variable of the struct, e.g. LOG_SYSLOG_FACILITY.
+// Malformed values leave the field untouched and are reported by Validate,
+// until the next ApplyEnv.
+func (o *Options) ApplyEnv(prefix string) {
+	o.envErrs = applyEnv(reflect.ValueOf(o).Elem(), strings.TrimSuffix(prefix, "_"))
+}
+
+func applyEnv(value reflect.Value, prefix string) []error {
+	var errs []error
+	for i := 0; i < value.NumField(); i++ {
+		name := strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		key := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		if value.Field(i).Kind() == reflect.Struct {
+			errs = append(errs, applyEnv(value.Field(i), key)...)
+
+			continue
+		}
+		env, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+		if err := setFromEnv(value.Field(i), env); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value %q of %s: %w", env, key, err))
+		}
+	}
+
+	return errs
+}
+
+func setFromEnv(field reflect.Value, env string) error {
+	switch field.Kind() { //nolint: exhaustive // other kinds are json encoded.
+	case reflect.String:
+		field.SetString(env)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(env)
+		if err != nil {
+			return err //nolint: wrapcheck // wrapped by the caller.
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(env, 10, field.Type().Bits())
+		if err != nil {
+			return err //nolint: wrapcheck // wrapped by the caller.
+		}
+		field.SetInt(n)
+	case reflect.Slice, reflect.Map:
+		if field.Type() == reflect.TypeOf([]string(nil)) {
+			field.Set(reflect.ValueOf(splitEnvList(env)))
+
+			return nil
+		}
+		if field.Type() != reflect.TypeOf(map[string]string(nil)) {
+			return setFromJSON(field, env)
+		}
+		m := make(map[string]string)
+		for _, pair := range splitEnvList(env) {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("%q must be formatted as key=value", pair)
+			}
+			m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+		field.Set(reflect.ValueOf(m))
+	default:
+		return setFromJSON(field, env)
+	}
+
+	return nil
+}
+
+func setFromJSON(field reflect.Value, env string) error {
+	value := reflect.New(field.Type())
+	if err := json.Unmarshal([]byte(env), value.Interface()); err != nil {
+		return err //nolint: wrapcheck // wrapped by the caller.
+	}
+	field.Set(value.Elem())
+
+	return nil
+}
+
+func splitEnvList(env string) []string {
+	res := []string{}
+	for _, s := range strings.Split(env, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			res = append(res, s)
+		}
+	}
+
+	return res
+}
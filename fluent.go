@@ -0,0 +1,216 @@
+package log
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	fluentForward       = "forward"
+	fluentPackedForward = "packed-forward"
+
+	defaultFluentPort = "24224"
+	defaultFluentTag  = "log"
+
+	fluentAckTimeout = 30 * time.Second
+)
+
+// nolint: gochecknoinits // register the fluent output path scheme.
+func init() {
+	_sinkSchemes["fluent"] = sinkScheme{open: openFluentSink, config: fluentConfig, buffered: true}
+}
+
+// FluentOptions configures the fluent://host:24224 output paths, which send
+// the entries to fluentd or fluent bit over the forward protocol. The tag of
+// an entry is the name of its logger, prefixed by the name of the Options.
+type FluentOptions struct {
+	// Mode is forward, sending the entries of a batch as an array, or
+	// packed-forward, sending them as a single binary.
+	Mode string `json:"mode"        mapstructure:"mode"`
+	// RequireAck asks the server to acknowledge every batch, the batches
+	// which are not acknowledged are sent again from the first of them.
+	RequireAck bool         `json:"require-ack" mapstructure:"require-ack"`
+	Batch      BatchOptions `json:"batch"       mapstructure:"batch"`
+}
+
+func (o FluentOptions) validate() []error {
+	var errs []error
+	switch o.Mode {
+	case "", fluentForward, fluentPackedForward:
+	default:
+		errs = append(errs, fmt.Errorf("not a valid fluent mode: %q", o.Mode))
+	}
+
+	return append(errs, o.Batch.validate("fluent")...)
+}
+
+type fluentSinkConfig struct {
+	fluent FluentOptions
+	name   string
+	stream streamSinkConfig
+}
+
+func fluentConfig(opts *Options) interface{} {
+	return fluentSinkConfig{fluent: opts.Fluent, name: opts.Name, stream: streamConfig(opts).(streamSinkConfig)}
+}
+
+func openFluentSink(u *url.URL, opts *Options) (*sink, error) {
+	if u.Hostname() == "" {
+		return nil, errors.New("missing host of fluent output path")
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), defaultFluentPort)
+	}
+	dialer := &net.Dialer{Timeout: streamDialTimeout}
+	conn := &connWriter{dial: func() (net.Conn, error) {
+		return dialer.Dial("tcp", host)
+	}}
+	cfg := encoderConfigFromOpts(opts)
+	name, fluent := opts.Name, opts.Fluent
+
+	// a buffered entry is its tag followed by its [time, record] event, both
+	// encoded as MessagePack.
+	encode := func(_ zapcore.Encoder, ent zapcore.Entry, fields []zapcore.Field) ([]byte, error) {
+		values := zapcore.NewMapObjectEncoder()
+		for _, f := range fields {
+			f.AddTo(values)
+		}
+		record := values.Fields
+		// the values of empty keys are left out.
+		set := func(key string, value interface{}) {
+			if key != "" {
+				record[key] = value
+			}
+		}
+		set(cfg.LevelKey, ent.Level.String())
+		set(cfg.MessageKey, ent.Message)
+		if ent.LoggerName != "" {
+			set(cfg.NameKey, ent.LoggerName)
+		}
+		if ent.Caller.Defined {
+			set(cfg.CallerKey, ent.Caller.TrimmedPath())
+		}
+		if ent.Stack != "" {
+			set(cfg.StacktraceKey, ent.Stack)
+		}
+		tag := joinName(name, ent.LoggerName)
+		if tag == "" {
+			tag = defaultFluentTag
+		}
+		b := appendMsgpackString(nil, tag)
+		b = appendMsgpackArrayHeader(b, 2)
+		b = appendMsgpackEventTime(b, ent.Time)
+
+		return appendMsgpack(b, record), nil
+	}
+
+	// the consecutive entries of a tag are sent in a single message, after a
+	// failure only the messages which were not delivered are sent again.
+	send := func(batch [][]byte) error {
+		for i := 0; i < len(batch); {
+			tag, event := splitFluentEntry(batch[i])
+			events := [][]byte{event}
+			n := i + 1
+			for ; n < len(batch); n++ {
+				next, event := splitFluentEntry(batch[n])
+				if next != tag {
+					break
+				}
+				events = append(events, event)
+			}
+			if err := sendFluentMessage(conn, fluent, tag, events); err != nil {
+				return &retryError{err: err, retry: batch[i:]}
+			}
+			i = n
+		}
+
+		return nil
+	}
+
+	policy := opts.Fluent.Batch.policy()
+	policy.retries = -1
+	policy.interrupt = conn.interrupt
+	s, err := newBatchSink(u, opts, policy, encode, send)
+	if err != nil {
+		return nil, err
+	}
+	closeBuffer := s.close
+	s.close = func() error {
+		return multierr.Combine(closeBuffer(), conn.close())
+	}
+
+	return s, nil
+}
+
+// splitFluentEntry returns the tag and the event of a buffered entry.
+func splitFluentEntry(record []byte) (string, []byte) {
+	r := bytes.NewReader(record)
+	tag, err := decodeMsgpack(r)
+	if err != nil {
+		return "", nil
+	}
+	s, _ := tag.(string)
+
+	return s, record[len(record)-r.Len():]
+}
+
+// sendFluentMessage sends the events of tag in a message of the mode of
+// fluent, waiting for its acknowledgment when it is required.
+func sendFluentMessage(conn *connWriter, fluent FluentOptions, tag string, events [][]byte) error {
+	msg := appendMsgpackArrayHeader(nil, 3)
+	msg = appendMsgpackString(msg, tag)
+	if fluent.Mode == fluentPackedForward {
+		msg = appendMsgpackBin(msg, bytes.Join(events, nil))
+	} else {
+		msg = appendMsgpackArrayHeader(msg, len(events))
+		for _, event := range events {
+			msg = append(msg, event...)
+		}
+	}
+	option := map[string]interface{}{"size": len(events)}
+	var chunk string
+	if fluent.RequireAck {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return fmt.Errorf("generate fluent chunk id: %w", err)
+		}
+		chunk = base64.StdEncoding.EncodeToString(id)
+		option["chunk"] = chunk
+	}
+	msg = appendMsgpack(msg, option)
+
+	if err := conn.send(msg); err != nil {
+		return err
+	}
+	if chunk == "" {
+		return nil
+	}
+
+	err := conn.receive(fluentAckTimeout, func(r io.Reader) error {
+		resp, err := decodeMsgpack(r)
+		if err != nil {
+			return err
+		}
+		if ack, _ := resp.(map[string]interface{}); ack["ack"] != chunk {
+			return fmt.Errorf("unexpected fluent ack: %v", resp)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("read fluent ack: %w", err)
+	}
+
+	return nil
+}
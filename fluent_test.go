@@ -0,0 +1,227 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fluentEvent struct {
+	Tag    string
+	Time   time.Time
+	Record map[string]interface{}
+}
+
+// forwardServer decodes the messages of the forward protocol it receives and
+// acknowledges their chunks, closing the connection instead of acknowledging
+// the messages numbered skipAcks, from 1.
+type forwardServer struct {
+	t        *testing.T
+	ln       net.Listener
+	mu       sync.Mutex
+	skipAcks map[int]bool
+	messages int
+	events   []fluentEvent
+}
+
+func newForwardServer(t *testing.T, skipAcks ...int) *forwardServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	s := &forwardServer{t: t, ln: ln, skipAcks: map[int]bool{}}
+	for _, n := range skipAcks {
+		s.skipAcks[n] = true
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *forwardServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		msg, err := decodeMsgpack(r)
+		if err != nil {
+			return
+		}
+		fields, _ := msg.([]interface{})
+		if !assert.Len(s.t, fields, 3) {
+			return
+		}
+		tag, _ := fields[0].(string)
+		entries, ok := fields[1].([]interface{})
+		if packed, isBin := fields[1].([]byte); isBin {
+			entries, ok = nil, true
+			for pr := bytes.NewReader(packed); pr.Len() != 0; {
+				entry, err := decodeMsgpack(pr)
+				assert.Nil(s.t, err)
+				entries = append(entries, entry)
+			}
+		}
+		assert.True(s.t, ok, "unexpected entries: %v", fields[1])
+		option, _ := fields[2].(map[string]interface{})
+		assert.Equal(s.t, int64(len(entries)), option["size"])
+
+		s.mu.Lock()
+		s.messages++
+		if chunk, ok := option["chunk"]; ok && s.skipAcks[s.messages] {
+			s.mu.Unlock()
+
+			return
+		} else if ok {
+			_, _ = conn.Write(appendMsgpack(nil, map[string]interface{}{"ack": chunk}))
+		}
+		for _, entry := range entries {
+			event, _ := entry.([]interface{})
+			ext, _ := event[0].(msgpackExt)
+			assert.Equal(s.t, int8(msgpackEventTime), ext.Type)
+			sec, nsec := readMsgpackEventTime(ext.Data)
+			record, _ := event[1].(map[string]interface{})
+			s.events = append(s.events, fluentEvent{Tag: tag, Time: time.Unix(sec, nsec), Record: record})
+		}
+		s.mu.Unlock()
+	}
+}
+
+func readMsgpackEventTime(data []byte) (int64, int64) {
+	sec, _ := readMsgpackLength(bytes.NewReader(data[:4]), 4)
+	nsec, _ := readMsgpackLength(bytes.NewReader(data[4:]), 4)
+
+	return int64(sec), int64(nsec)
+}
+
+func Test_Fluent_Forward(t *testing.T) {
+	server := newForwardServer(t, 2)
+	defer server.ln.Close()
+
+	opts := NewOptions()
+	opts.Name = "app"
+	opts.OutputPaths = []string{"fluent://" + server.ln.Addr().String()}
+	opts.Fluent.RequireAck = true
+	opts.Fluent.Batch.WaitInMS = 60000
+	logger, closeFunc, err := New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	logger.Infow("first", "user", "alice", "latency", 1500*time.Microsecond)
+	logger.WithName("db").Warnw("second", "tags", []string{"a", "b"})
+	logger.Info("third")
+	// the second message is not acknowledged, it is sent again with the third
+	// but the first is not.
+	logger.Flush()
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, 4, server.messages)
+	assert.Len(t, server.events, 3)
+	first := server.events[0]
+	assert.Equal(t, "app", first.Tag)
+	assert.WithinDuration(t, time.Now(), first.Time, time.Minute)
+	assert.Equal(t, map[string]interface{}{
+		"level": "info", "msg": "first", "user": "alice", "latency": 1.5,
+	}, first.Record)
+	assert.Equal(t, "app.db", server.events[1].Tag)
+	assert.Equal(t, "warn", server.events[1].Record["level"])
+	assert.Equal(t, "db", server.events[1].Record["logger"])
+	assert.Equal(t, []interface{}{"a", "b"}, server.events[1].Record["tags"])
+	assert.Equal(t, "app", server.events[2].Tag)
+	assert.Equal(t, "third", server.events[2].Record["msg"])
+}
+
+func Test_Fluent_PackedForward(t *testing.T) {
+	server := newForwardServer(t)
+	defer server.ln.Close()
+
+	opts := NewOptions()
+	opts.OutputPaths = []string{"fluent://" + server.ln.Addr().String()}
+	opts.Fluent.Mode = fluentPackedForward
+	opts.Fluent.Batch.WaitInMS = 60000
+	logger, closeFunc, err := New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	logger.Info("first")
+	logger.Info("second")
+	logger.Flush()
+
+	// without acknowledgments, the entries are delivered once the server read
+	// them.
+	assert.Eventually(t, func() bool {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+
+		return len(server.events) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, 1, server.messages)
+	assert.Equal(t, defaultFluentTag, server.events[0].Tag)
+	assert.Equal(t, "second", server.events[1].Record["msg"])
+}
+
+func Test_Fluent_Close(t *testing.T) {
+	// the server reads the messages but never acknowledges them.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+
+	opts := NewOptions()
+	opts.OutputPaths = []string{"fluent://" + ln.Addr().String()}
+	opts.Fluent.RequireAck = true
+	logger, closeFunc, err := New(opts)
+	assert.Nil(t, err)
+
+	logger.Info("unacknowledged")
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	closeFunc()
+	assert.Less(t, time.Since(start), fluentAckTimeout/2)
+}
+
+func Test_msgpack(t *testing.T) {
+	values := []interface{}{
+		nil, true, false, int64(-1), int64(-200), int64(-40000), int64(-3e9), int64(7),
+		int64(200), int64(70000), int64(5e9), 1.25, "", "short",
+		string(bytes.Repeat([]byte("x"), 300)), []byte("bin"),
+		[]interface{}{int64(1), "a"}, map[string]interface{}{"k": []interface{}{}},
+	}
+	for _, v := range values {
+		decoded, err := decodeMsgpack(bytes.NewReader(appendMsgpack(nil, v)))
+		assert.Nil(t, err)
+		assert.Equal(t, v, decoded)
+	}
+
+	// values of unknown types are encoded like JSON.
+	decoded, err := decodeMsgpack(bytes.NewReader(appendMsgpack(nil, struct {
+		Name string `json:"name"`
+	}{"x"})))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "x"}, decoded)
+}
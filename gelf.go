@@ -0,0 +1,462 @@
+package log
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	gelfFormat  = "gelf"
+	gelfVersion = "1.1"
+
+	defaultGELFPort      = "12201"
+	defaultGELFChunkSize = 1420
+
+	// a chunk starts with the magic bytes, the message id, its sequence number
+	// and the number of chunks.
+	gelfChunkHeaderSize = 12
+	gelfMaxChunks       = 128
+)
+
+// nolint: gochecknoinits // register the gelf output path scheme.
+func init() {
+	_sinkSchemes["gelf+udp"] = sinkScheme{open: openGELFSink, config: gelfConfig}
+}
+
+// GELFOptions configures the gelf+udp://host:12201 output paths, which send
+// the entries encoded as GELF to Graylog over UDP.
+type GELFOptions struct {
+	// Compress compresses the messages with gzip.
+	Compress bool `json:"compress"   mapstructure:"compress"`
+	// ChunkSize is the max size of the datagrams, larger messages are sent in
+	// up to 128 chunks. 0 means 1420.
+	ChunkSize int `json:"chunk-size" mapstructure:"chunk-size"`
+}
+
+func (o GELFOptions) validate() []error {
+	if o.ChunkSize < 0 || (o.ChunkSize > 0 && o.ChunkSize <= gelfChunkHeaderSize) {
+		return []error{fmt.Errorf("not a valid gelf chunk size: %d", o.ChunkSize)}
+	}
+
+	return nil
+}
+
+func gelfConfig(opts *Options) interface{} {
+	cfg := opts.GELF
+	if cfg.ChunkSize == 0 {
+		cfg.ChunkSize = defaultGELFChunkSize
+	}
+
+	return cfg
+}
+
+// gelfEncoderConfig remaps the keys of cfg to GELF: the stack trace is the
+// full message and the level is a syslog severity.
+func gelfEncoderConfig(cfg zapcore.EncoderConfig) zapcore.EncoderConfig {
+	cfg.TimeKey = "timestamp"
+	cfg.LevelKey = "level"
+	cfg.NameKey = "_logger"
+	cfg.CallerKey = "_caller"
+	cfg.MessageKey = "short_message"
+	cfg.StacktraceKey = "full_message"
+	cfg.FunctionKey = ""
+	cfg.EncodeTime = zapcore.EpochTimeEncoder
+	cfg.EncodeLevel = func(lvl zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+		enc.AppendInt(syslogSeverity(lvl))
+	}
+
+	return cfg
+}
+
+// gelfEncoder is a JSON encoder writing GELF 1.1, the keys of the fields are
+// prefixed with an underscore as additional fields. Additional fields hold
+// single values, so nested objects and namespaces are flattened into keys
+// joined by underscores, and the elements of arrays get their index as key,
+// e.g. _tags_0 and _tags_1. The reserved _id becomes _id_.
+type gelfEncoder struct {
+	zapcore.Encoder
+	// prefix is the key of the namespaces and objects being encoded.
+	prefix []string
+	// fields counts the fields added, to tell empty objects and arrays.
+	fields int
+}
+
+func newGELFEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	enc := zapcore.NewJSONEncoder(gelfEncoderConfig(cfg))
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "localhost"
+	}
+	enc.AddString("version", gelfVersion)
+	enc.AddString("host", host)
+
+	return &gelfEncoder{Encoder: enc}
+}
+
+func (enc *gelfEncoder) Clone() zapcore.Encoder {
+	return &gelfEncoder{Encoder: enc.Encoder.Clone(), prefix: append([]string(nil), enc.prefix...)}
+}
+
+func (enc *gelfEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := &gelfEncoder{Encoder: enc.Encoder.Clone(), prefix: append([]string(nil), enc.prefix...)}
+	for _, f := range fields {
+		f.AddTo(final)
+	}
+
+	return final.Encoder.EncodeEntry(ent, nil) //nolint: wrapcheck // keep the error of the encoder.
+}
+
+// key returns the additional field of key, prefixed by the keys of the
+// enclosing namespaces and objects.
+func (enc *gelfEncoder) key(key string) string {
+	enc.fields++
+	field := "_" + strings.Join(append(enc.prefix[:len(enc.prefix):len(enc.prefix)], key), "_")
+	if field == "_id" {
+		return "_id_"
+	}
+
+	return field
+}
+
+// addObject adds the fields of obj with key added to the prefix, or key set
+// to {} when it has no fields. The namespaces opened by obj end with it.
+func (enc *gelfEncoder) addObject(key string, obj zapcore.ObjectMarshaler) error {
+	n, depth := enc.fields, len(enc.prefix)
+	enc.prefix = append(enc.prefix, key)
+	err := obj.MarshalLogObject(enc)
+	enc.prefix = enc.prefix[:depth]
+	if enc.fields == n {
+		enc.AddString(key, "{}")
+	}
+
+	return err //nolint: wrapcheck // keep the error of the marshaler.
+}
+
+// addArray adds the elements of arr with their index added to key, or key set
+// to [] when it has no elements.
+func (enc *gelfEncoder) addArray(key string, arr zapcore.ArrayMarshaler) error {
+	elements := &gelfArrayEncoder{enc: enc, key: key}
+	err := arr.MarshalLogArray(elements)
+	if elements.n == 0 {
+		enc.AddString(key, "[]")
+	}
+
+	return err //nolint: wrapcheck // keep the error of the marshaler.
+}
+
+// addValue adds a value decoded from JSON, flattening its objects and arrays.
+func (enc *gelfEncoder) addValue(key string, v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			enc.AddString(key, "{}")
+
+			return
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		depth := len(enc.prefix)
+		enc.prefix = append(enc.prefix, key)
+		for _, k := range keys {
+			enc.addValue(k, v[k])
+		}
+		enc.prefix = enc.prefix[:depth]
+	case []interface{}:
+		if len(v) == 0 {
+			enc.AddString(key, "[]")
+
+			return
+		}
+		for i, e := range v {
+			enc.addValue(key+"_"+strconv.Itoa(i), e)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			enc.AddInt64(key, n)
+		} else {
+			f, _ := v.Float64()
+			enc.AddFloat64(key, f)
+		}
+	case string:
+		enc.AddString(key, v)
+	case bool:
+		enc.AddBool(key, v)
+	default:
+		_ = enc.Encoder.AddReflected(enc.key(key), v)
+	}
+}
+
+func (enc *gelfEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	return enc.addArray(key, arr)
+}
+
+func (enc *gelfEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	return enc.addObject(key, obj)
+}
+
+func (enc *gelfEncoder) AddReflected(key string, val interface{}) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err //nolint: wrapcheck // keep the error of the marshaler.
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err //nolint: wrapcheck // keep the error of the decoder.
+	}
+	enc.addValue(key, v)
+
+	return nil
+}
+
+func (enc *gelfEncoder) AddBinary(key string, val []byte) {
+	enc.Encoder.AddBinary(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) AddByteString(key string, val []byte) {
+	enc.Encoder.AddByteString(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) AddBool(key string, val bool) {
+	enc.Encoder.AddBool(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) AddComplex128(key string, val complex128) {
+	enc.Encoder.AddComplex128(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) AddComplex64(key string, val complex64) {
+	enc.Encoder.AddComplex64(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) AddDuration(key string, val time.Duration) {
+	enc.Encoder.AddDuration(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) AddFloat64(key string, val float64) {
+	enc.Encoder.AddFloat64(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) AddFloat32(key string, val float32) {
+	enc.Encoder.AddFloat32(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) AddInt(key string, val int) {
+	enc.Encoder.AddInt(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) AddInt64(key string, val int64) {
+	enc.Encoder.AddInt64(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) AddInt32(key string, val int32) {
+	enc.Encoder.AddInt32(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) AddInt16(key string, val int16) {
+	enc.Encoder.AddInt16(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) AddInt8(key string, val int8) {
+	enc.Encoder.AddInt8(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) AddString(key string, val string) {
+	enc.Encoder.AddString(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) AddTime(key string, val time.Time) {
+	enc.Encoder.AddTime(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) AddUint(key string, val uint) {
+	enc.Encoder.AddUint(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) AddUint64(key string, val uint64) {
+	enc.Encoder.AddUint64(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) AddUint32(key string, val uint32) {
+	enc.Encoder.AddUint32(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) AddUint16(key string, val uint16) {
+	enc.Encoder.AddUint16(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) AddUint8(key string, val uint8) {
+	enc.Encoder.AddUint8(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) AddUintptr(key string, val uintptr) {
+	enc.Encoder.AddUintptr(enc.key(key), val)
+}
+
+func (enc *gelfEncoder) OpenNamespace(key string) {
+	enc.prefix = append(enc.prefix, key)
+}
+
+// gelfArrayEncoder adds the elements of an array as fields keyed by their
+// index.
+type gelfArrayEncoder struct {
+	enc *gelfEncoder
+	key string
+	n   int
+}
+
+// next returns the key of the next element.
+func (a *gelfArrayEncoder) next() string {
+	key := a.key + "_" + strconv.Itoa(a.n)
+	a.n++
+
+	return key
+}
+
+func (a *gelfArrayEncoder) AppendArray(arr zapcore.ArrayMarshaler) error {
+	return a.enc.addArray(a.next(), arr)
+}
+
+func (a *gelfArrayEncoder) AppendObject(obj zapcore.ObjectMarshaler) error {
+	return a.enc.addObject(a.next(), obj)
+}
+
+func (a *gelfArrayEncoder) AppendReflected(val interface{}) error {
+	return a.enc.AddReflected(a.next(), val)
+}
+
+func (a *gelfArrayEncoder) AppendBool(val bool)              { a.enc.AddBool(a.next(), val) }
+func (a *gelfArrayEncoder) AppendByteString(val []byte)      { a.enc.AddByteString(a.next(), val) }
+func (a *gelfArrayEncoder) AppendComplex128(val complex128)  { a.enc.AddComplex128(a.next(), val) }
+func (a *gelfArrayEncoder) AppendComplex64(val complex64)    { a.enc.AddComplex64(a.next(), val) }
+func (a *gelfArrayEncoder) AppendFloat64(val float64)        { a.enc.AddFloat64(a.next(), val) }
+func (a *gelfArrayEncoder) AppendFloat32(val float32)        { a.enc.AddFloat32(a.next(), val) }
+func (a *gelfArrayEncoder) AppendInt(val int)                { a.enc.AddInt(a.next(), val) }
+func (a *gelfArrayEncoder) AppendInt64(val int64)            { a.enc.AddInt64(a.next(), val) }
+func (a *gelfArrayEncoder) AppendInt32(val int32)            { a.enc.AddInt32(a.next(), val) }
+func (a *gelfArrayEncoder) AppendInt16(val int16)            { a.enc.AddInt16(a.next(), val) }
+func (a *gelfArrayEncoder) AppendInt8(val int8)              { a.enc.AddInt8(a.next(), val) }
+func (a *gelfArrayEncoder) AppendString(val string)          { a.enc.AddString(a.next(), val) }
+func (a *gelfArrayEncoder) AppendUint(val uint)              { a.enc.AddUint(a.next(), val) }
+func (a *gelfArrayEncoder) AppendUint64(val uint64)          { a.enc.AddUint64(a.next(), val) }
+func (a *gelfArrayEncoder) AppendUint32(val uint32)          { a.enc.AddUint32(a.next(), val) }
+func (a *gelfArrayEncoder) AppendUint16(val uint16)          { a.enc.AddUint16(a.next(), val) }
+func (a *gelfArrayEncoder) AppendUint8(val uint8)            { a.enc.AddUint8(a.next(), val) }
+func (a *gelfArrayEncoder) AppendUintptr(val uintptr)        { a.enc.AddUintptr(a.next(), val) }
+func (a *gelfArrayEncoder) AppendDuration(val time.Duration) { a.enc.AddDuration(a.next(), val) }
+func (a *gelfArrayEncoder) AppendTime(val time.Time)         { a.enc.AddTime(a.next(), val) }
+
+// gelfWriter sends GELF messages over UDP, chunking the ones larger than a
+// datagram.
+type gelfWriter struct {
+	conn      net.Conn
+	compress  bool
+	chunkSize int
+	encoder   zapcore.Encoder
+}
+
+// openGELFSink opens gelf+udp://host[:port], which always encodes the entries
+// as GELF whatever the format of the logger.
+func openGELFSink(u *url.URL, opts *Options) (*sink, error) {
+	if u.Hostname() == "" {
+		return nil, errors.New("missing host of gelf output path")
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), defaultGELFPort)
+	}
+	conn, err := net.Dial("udp", host)
+	if err != nil {
+		return nil, fmt.Errorf("dial gelf output path: %w", err)
+	}
+	cfg, _ := gelfConfig(opts).(GELFOptions)
+	w := &gelfWriter{
+		conn:      conn,
+		compress:  cfg.Compress,
+		chunkSize: cfg.ChunkSize,
+		encoder:   newGELFEncoder(encoderConfigFromOpts(opts)),
+	}
+	newCore := func(_ zapcore.Encoder, enabler zapcore.LevelEnabler) zapcore.Core {
+		return &entryCore{LevelEnabler: enabler, write: w.writeEntry, sync: w.Sync}
+	}
+
+	return &sink{
+		WriteSyncer: coreWriter{newCore(nil, zapcore.DebugLevel)},
+		close:       conn.Close,
+		newCore:     newCore,
+	}, nil
+}
+
+func (w *gelfWriter) writeEntry(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := w.encoder.EncodeEntry(ent, fields)
+	if err != nil {
+		return err //nolint: wrapcheck // keep the error of the encoder.
+	}
+	defer buf.Free()
+	msg := buf.Bytes()
+	if n := len(msg); n != 0 && msg[n-1] == '\n' {
+		msg = msg[:n-1]
+	}
+	if w.compress {
+		msg = gzipBody(msg)
+	}
+
+	return w.send(msg)
+}
+
+// send sends msg in a single datagram when it fits, or in chunks sharing a
+// random message id.
+func (w *gelfWriter) send(msg []byte) error {
+	if len(msg) <= w.chunkSize {
+		_, err := w.conn.Write(msg)
+
+		return err //nolint: wrapcheck // keep the error of the connection.
+	}
+	size := w.chunkSize - gelfChunkHeaderSize
+	count := (len(msg) + size - 1) / size
+	if count > gelfMaxChunks {
+		atomic.AddUint64(&_droppedEntries, 1)
+
+		return fmt.Errorf("gelf message of %d bytes exceeds %d chunks", len(msg), gelfMaxChunks)
+	}
+	chunk := make([]byte, gelfChunkHeaderSize, w.chunkSize)
+	chunk[0], chunk[1] = 0x1e, 0x0f
+	if _, err := rand.Read(chunk[2:10]); err != nil {
+		return fmt.Errorf("generate gelf message id: %w", err)
+	}
+	chunk[11] = byte(count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(msg) {
+			end = len(msg)
+		}
+		chunk[10] = byte(i)
+		if _, err := w.conn.Write(append(chunk[:gelfChunkHeaderSize], msg[i*size:end]...)); err != nil {
+			return err //nolint: wrapcheck // keep the error of the connection.
+		}
+	}
+
+	return nil
+}
+
+func (w *gelfWriter) Sync() error {
+	return nil
+}
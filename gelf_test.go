@@ -0,0 +1,174 @@
+package log_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/huanghe314/log"
+)
+
+// readGELF reads n messages from conn, reassembling the chunked ones and
+// decompressing the gzipped ones.
+func readGELF(t *testing.T, conn net.PacketConn, n int) []map[string]interface{} {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msgs []map[string]interface{}
+	chunks := map[string][][]byte{}
+	buf := make([]byte, 65536)
+	for len(msgs) < n {
+		size, _, err := conn.ReadFrom(buf)
+		if !assert.Nil(t, err) {
+			return msgs
+		}
+		data := append([]byte(nil), buf[:size]...)
+		if data[0] == 0x1e && data[1] == 0x0f {
+			id, seq, count := string(data[2:10]), int(data[10]), int(data[11])
+			if chunks[id] == nil {
+				chunks[id] = make([][]byte, count)
+			}
+			chunks[id][seq] = data[12:]
+			complete := true
+			for _, chunk := range chunks[id] {
+				complete = complete && chunk != nil
+			}
+			if !complete {
+				continue
+			}
+			data = bytes.Join(chunks[id], nil)
+		}
+		if data[0] == 0x1f && data[1] == 0x8b {
+			gz, err := gzip.NewReader(bytes.NewReader(data))
+			assert.Nil(t, err)
+			data, err = io.ReadAll(gz)
+			assert.Nil(t, err)
+		}
+		var msg map[string]interface{}
+		assert.Nil(t, json.Unmarshal(data, &msg), string(data))
+		msgs = append(msgs, msg)
+	}
+
+	return msgs
+}
+
+func Test_GELF_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{"gelf+udp://" + conn.LocalAddr().String()}
+	opts.GELF.ChunkSize = 100
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+
+	logger.WithValues("user", "alice").WithName("api").Warnw("short", "status", 503)
+	long := strings.Repeat("a long message ", 40)
+	logger.Info(long)
+	assert.Nil(t, closeFunc())
+
+	opts.GELF.Compress = true
+	logger, closeFunc, err = log.New(opts)
+	assert.Nil(t, err)
+	logger.Info(long)
+	assert.Nil(t, closeFunc())
+
+	msgs := readGELF(t, conn, 3)
+	assert.Len(t, msgs, 3)
+	host, _ := os.Hostname()
+	short := msgs[0]
+	assert.Equal(t, "1.1", short["version"])
+	assert.Equal(t, host, short["host"])
+	assert.Equal(t, "short", short["short_message"])
+	assert.Equal(t, float64(4), short["level"])
+	assert.InDelta(t, float64(time.Now().Unix()), short["timestamp"], 60)
+	assert.Equal(t, "alice", short["_user"])
+	assert.Equal(t, float64(503), short["_status"])
+	assert.Equal(t, "api", short["_logger"])
+	// the long message is sent in chunks, compressed or not.
+	assert.Equal(t, long, msgs[1]["short_message"])
+	assert.Equal(t, float64(6), msgs[1]["level"])
+	assert.Equal(t, long, msgs[2]["short_message"])
+}
+
+func Test_GELF_Format(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	opts := log.NewOptions()
+	opts.Format = "gelf"
+	opts.OutputPaths = []string{path}
+	opts.ErrorOutputPaths = []string{path}
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+
+	assert.Panics(t, func() { logger.Panicw("degraded", "id", 7) })
+	assert.Nil(t, closeFunc())
+
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	var msg map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(strings.Split(string(data), "\n")[0]), &msg))
+	assert.Equal(t, "degraded", msg["short_message"])
+	assert.Equal(t, float64(1), msg["level"])
+	// _id is reserved by GELF.
+	assert.Equal(t, float64(7), msg["_id_"])
+	assert.NotContains(t, msg, "_id")
+	assert.Contains(t, msg["full_message"], "Test_GELF_Format")
+	assert.NotContains(t, msg, "id")
+
+	opts.GELF.ChunkSize = 12
+	assert.NotEmpty(t, opts.Validate())
+}
+
+func Test_GELF_Flatten(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	opts := log.NewOptions()
+	opts.Format = "gelf"
+	opts.OutputPaths = []string{path}
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+
+	logger.Info("nested",
+		log.Object("req", zapcore.ObjectMarshalerFunc(func(obj zapcore.ObjectEncoder) error {
+			obj.AddString("method", "GET")
+			obj.OpenNamespace("ns")
+			obj.AddInt("status", 200)
+
+			return nil
+		})),
+		log.Strings("tags", []string{"a", "b"}),
+		log.Reflect("user", map[string]interface{}{"name": "alice", "roles": []string{"admin"}, "age": 30}),
+		log.Namespace("ctx"),
+		log.String("after", "x"),
+	)
+	assert.Nil(t, closeFunc())
+
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	var msg map[string]interface{}
+	assert.Nil(t, json.Unmarshal(data, &msg))
+	delete(msg, "timestamp")
+	delete(msg, "host")
+	assert.Equal(t, map[string]interface{}{
+		"version":        "1.1",
+		"level":          float64(6),
+		"short_message":  "nested",
+		"_req_method":    "GET",
+		"_req_ns_status": float64(200),
+		"_tags_0":        "a",
+		"_tags_1":        "b",
+		"_user_age":      float64(30),
+		"_user_name":     "alice",
+		"_user_roles_0":  "admin",
+		"_ctx_after":     "x",
+	}, msg)
+}
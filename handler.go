@@ -0,0 +1,123 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go.uber.org/zap/zapcore"
+)
+
+type levelHandler struct{}
+
+// LevelHandler returns a http.Handler which reports on or changes the level of
+// the global logger and of its named loggers. It follows the semantics of
+// zap.AtomicLevel.ServeHTTP.
+//
+// # GET
+//
+// The GET request returns a JSON description of the current levels like:
+//
+//	{"level":"info","loggers":{"storage":"warn"}}
+//
+// # PUT
+//
+// The PUT request changes a level and returns the same description as GET. The
+// optional logger parameter selects the named logger to change, the root
+// logger is changed when it is empty. Two content types are supported:
+//
+//	Content-Type: application/x-www-form-urlencoded
+//
+// With this content type, the parameters can be provided through the request
+// body or the query string:
+//
+//	curl -X PUT localhost:8080/log/level?level=debug
+//	curl -X PUT localhost:8080/log/level -d level=warn -d logger=storage
+//
+// For any other content type, the payload is expected to be JSON encoded:
+//
+//	curl -X PUT localhost:8080/log/level -H "Content-Type: application/json" \
+//	  -d '{"level":"warn","logger":"storage"}'
+func LevelHandler() http.Handler {
+	return levelHandler{}
+}
+
+type levelPayload struct {
+	Level   zapcore.Level            `json:"level"`
+	Loggers map[string]zapcore.Level `json:"loggers,omitempty"`
+}
+
+type levelErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func (levelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	enc := json.NewEncoder(w)
+	levels := _logger.Load().levels
+
+	switch r.Method {
+	case http.MethodGet:
+		_ = enc.Encode(currentLevels(levels))
+	case http.MethodPut:
+		name, level, err := decodeLevelRequest(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = enc.Encode(levelErrorResponse{Error: err.Error()})
+
+			return
+		}
+		levels.setLevel(name, level)
+		_ = enc.Encode(currentLevels(levels))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_ = enc.Encode(levelErrorResponse{Error: "Only GET and PUT are supported."})
+	}
+}
+
+func currentLevels(levels *levels) levelPayload {
+	payload := levelPayload{Level: levels.root.Level()}
+	for _, name := range levels.names() {
+		if payload.Loggers == nil {
+			payload.Loggers = make(map[string]zapcore.Level)
+		}
+		payload.Loggers[name] = levels.levelOf(name)
+	}
+
+	return payload
+}
+
+// decodeLevelRequest returns the logger name and the level requested by a PUT
+// request.
+func decodeLevelRequest(r *http.Request) (string, zapcore.Level, error) {
+	if r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+		lvl := r.FormValue("level")
+		if lvl == "" {
+			return "", 0, errors.New("must specify logging level")
+		}
+		var level zapcore.Level
+		if err := level.UnmarshalText([]byte(lvl)); err != nil {
+			return "", 0, fmt.Errorf("invalid logging level: %w", err)
+		}
+
+		return r.FormValue("logger"), level, nil
+	}
+
+	return decodeLevelJSON(r.Body)
+}
+
+func decodeLevelJSON(body io.Reader) (string, zapcore.Level, error) {
+	var pld struct {
+		Level  *zapcore.Level `json:"level"`
+		Logger string         `json:"logger"`
+	}
+	if err := json.NewDecoder(body).Decode(&pld); err != nil {
+		return "", 0, fmt.Errorf("malformed request body: %w", err)
+	}
+	if pld.Level == nil {
+		return "", 0, errors.New("must specify logging level")
+	}
+
+	return pld.Logger, *pld.Level, nil
+}
@@ -0,0 +1,103 @@
+package log_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/huanghe314/log"
+)
+
+func Test_LevelHandler(t *testing.T) {
+	defer log.Init(log.NewOptions())
+	log.Init(log.NewOptions())
+
+	server := httptest.NewServer(log.LevelHandler())
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		query       string
+		body        string
+		code        int
+		expected    string
+	}{
+		{
+			name:     "get",
+			method:   http.MethodGet,
+			code:     http.StatusOK,
+			expected: `{"level":"info"}`,
+		},
+		{
+			name:        "put form query",
+			method:      http.MethodPut,
+			contentType: "application/x-www-form-urlencoded",
+			query:       "?level=debug",
+			code:        http.StatusOK,
+			expected:    `{"level":"debug"}`,
+		},
+		{
+			name:        "put form named",
+			method:      http.MethodPut,
+			contentType: "application/x-www-form-urlencoded",
+			body:        "level=warn&logger=storage",
+			code:        http.StatusOK,
+			expected:    `{"level":"debug","loggers":{"storage":"warn"}}`,
+		},
+		{
+			name:        "put json",
+			method:      http.MethodPut,
+			contentType: "application/json",
+			body:        `{"level":"error","logger":"storage"}`,
+			code:        http.StatusOK,
+			expected:    `{"level":"debug","loggers":{"storage":"error"}}`,
+		},
+		{
+			name:     "put json without level",
+			method:   http.MethodPut,
+			body:     `{"logger":"storage"}`,
+			code:     http.StatusBadRequest,
+			expected: `{"error":"must specify logging level"}`,
+		},
+		{
+			name:        "put invalid level",
+			method:      http.MethodPut,
+			contentType: "application/x-www-form-urlencoded",
+			body:        "level=loud",
+			code:        http.StatusBadRequest,
+			expected:    `{"error":"invalid logging level: unrecognized level: \"loud\""}`,
+		},
+		{
+			name:     "post",
+			method:   http.MethodPost,
+			code:     http.StatusMethodNotAllowed,
+			expected: `{"error":"Only GET and PUT are supported."}`,
+		},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, server.URL+tt.query, strings.NewReader(tt.body))
+		assert.Nil(t, err, tt.name)
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err, tt.name)
+		body, err := io.ReadAll(resp.Body)
+		assert.Nil(t, err, tt.name)
+		resp.Body.Close()
+
+		assert.Equal(t, tt.code, resp.StatusCode, tt.name)
+		assert.JSONEq(t, tt.expected, string(body), tt.name)
+	}
+
+	assert.Equal(t, log.DebugLevel, log.GetLevel())
+	assert.Equal(t, log.ErrorLevel, log.WithName("storage").GetLevel())
+	assert.Equal(t, log.DebugLevel, log.WithName("scheduler").GetLevel())
+}
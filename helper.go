@@ -1,34 +1,89 @@
 package log
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"sort"
+	"strings"
 	"time"
 
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 func buildEncoder(cfg zap.Config) zapcore.Encoder {
-	if cfg.Encoding == jsonFormat {
+	switch cfg.Encoding {
+	case jsonFormat:
 		return zapcore.NewJSONEncoder(cfg.EncoderConfig)
+	case logfmtFormat:
+		return newLogfmtEncoder(cfg.EncoderConfig)
+	case ecsFormat:
+		return newECSEncoder(cfg.EncoderConfig)
+	case gelfFormat:
+		return newGELFEncoder(cfg.EncoderConfig)
 	}
 
 	return zapcore.NewConsoleEncoder(cfg.EncoderConfig)
 }
 
-func buildRotationOpts(options *Options) rotationOptions {
+func buildRotationOpts(options *Options) rotationPolicy {
 	if options == nil {
-		return _defaultRotateOpts
+		return rotationPolicy{defaults: _defaultRotateOpts}
 	}
 
-	return rotationOptions{
+	defaults := rotationOptions{
 		maxSize:    options.MaxSizeInMB,
 		maxAge:     options.MaxAgeInDays,
-		maxBackups: _defaultRotateOpts.maxBackups,
-		compress:   _defaultRotateOpts.compress,
+		maxBackups: options.MaxBackups,
+		compress:   options.Compress,
+		localTime:  options.LocalTime,
+		interval:   options.RotationInterval,
+		external:   options.ExternalRotation,
 	}
+	// unset values, e.g. of Options not made by NewOptions, keep the defaults.
+	// Only a path of Rotations can set them back to 0.
+	if defaults.maxSize == 0 {
+		defaults.maxSize = _defaultRotateOpts.maxSize
+	}
+	if defaults.maxAge == 0 {
+		defaults.maxAge = _defaultRotateOpts.maxAge
+	}
+	if defaults.maxBackups == 0 {
+		defaults.maxBackups = _defaultRotateOpts.maxBackups
+	}
+	paths := make(map[string]rotationOptions, len(options.Rotations))
+	for path, override := range options.Rotations {
+		paths[path] = overrideRotation(defaults, override)
+	}
+
+	return rotationPolicy{defaults: defaults, paths: paths}
+}
+
+// overrideRotation returns base overridden by the fields set in override.
+func overrideRotation(base rotationOptions, override RotationOptions) rotationOptions {
+	if override.MaxSizeInMB != nil {
+		base.maxSize = *override.MaxSizeInMB
+	}
+	if override.MaxAgeInDays != nil {
+		base.maxAge = *override.MaxAgeInDays
+	}
+	if override.MaxBackups != nil {
+		base.maxBackups = *override.MaxBackups
+	}
+	if override.Compress != nil {
+		base.compress = *override.Compress
+	}
+	if override.LocalTime != nil {
+		base.localTime = *override.LocalTime
+	}
+	if override.Interval != "" {
+		base.interval = override.Interval
+	}
+
+	return base
 }
 
 func zapConfigFromOpts(opts *Options) zap.Config {
@@ -38,6 +93,11 @@ func zapConfigFromOpts(opts *Options) zap.Config {
 		zapLevel = InfoLevel
 	}
 
+	var initialFields map[string]interface{}
+	if opts.Format == ecsFormat {
+		initialFields = ecsFields(opts.Name)
+	}
+
 	return zap.Config{
 		Level:             zap.NewAtomicLevelAt(zapLevel),
 		Development:       opts.Development,
@@ -51,72 +111,324 @@ func zapConfigFromOpts(opts *Options) zap.Config {
 		EncoderConfig:    encoderConfig,
 		OutputPaths:      opts.OutputPaths,
 		ErrorOutputPaths: opts.ErrorOutputPaths,
+		InitialFields:    initialFields,
 	}
 }
 
-func buildWriteSyncer(paths []string, options rotationOptions) (zapcore.WriteSyncer, error) {
-	var res []zapcore.WriteSyncer
-	var closers []func()
-	closeAll := func() {
-		for _, c := range closers {
-			c()
+// sink is an output opened by buildWriteSyncer.
+type sink struct {
+	zapcore.WriteSyncer
+	close func() error
+	// wrapper is set for sinks writing to other sinks, they are closed first.
+	wrapper bool
+	// newCore is set for sinks which need whole entries rather than encoded
+	// bytes, e.g. to map levels to syslog severities. Such sinks get a core of
+	// their own instead of being combined with the other ones.
+	newCore func(enc zapcore.Encoder, enabler zapcore.LevelEnabler) zapcore.Core
+	// spoolDir is the directory of the spool of the sink, if any.
+	spoolDir string
+}
+
+// entryCore is a zapcore.Core handing every entry together with its fields,
+// including the ones added by With, to write.
+type entryCore struct {
+	zapcore.LevelEnabler
+	fields []zapcore.Field
+	write  func(ent zapcore.Entry, fields []zapcore.Field) error
+	sync   func() error
+}
+
+func (c *entryCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(c.fields[:len(c.fields):len(c.fields)], fields...)
+
+	return &clone
+}
+
+func (c *entryCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+
+	return ce
+}
+
+func (c *entryCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if len(c.fields) != 0 {
+		fields = append(c.fields[:len(c.fields):len(c.fields)], fields...)
+	}
+	if err := c.write(ent, fields); err != nil {
+		return err
+	}
+	if ent.Level > zapcore.ErrorLevel {
+		// Since we may be crashing the program, sync the output.
+		return c.Sync()
+	}
+
+	return nil
+}
+
+func (c *entryCore) Sync() error {
+	return c.sync()
+}
+
+// coreWriter is a WriteSyncer writing every write to a core as the message of
+// an info entry.
+type coreWriter struct {
+	core zapcore.Core
+}
+
+func (w coreWriter) Write(p []byte) (int, error) {
+	ent := zapcore.Entry{Level: zapcore.InfoLevel, Time: time.Now(), Message: strings.TrimRight(string(p), "\n")}
+	if err := w.core.Write(ent, nil); err != nil {
+		return 0, err //nolint: wrapcheck // keep the error of the core.
+	}
+
+	return len(p), nil
+}
+
+func (w coreWriter) Sync() error {
+	return w.core.Sync() //nolint: wrapcheck // keep the error of the core.
+}
+
+// sinkScheme opens the sinks of output paths which are urls of a scheme.
+type sinkScheme struct {
+	open func(u *url.URL, opts *Options) (*sink, error)
+	// config returns the part of opts the sinks depend on, a sink is reopened
+	// when it changes.
+	config func(opts *Options) interface{}
+	// buffered tells whether the sinks buffer their entries, which can then
+	// be spooled.
+	buffered bool
+}
+
+var _sinkSchemes = map[string]sinkScheme{}
+
+// lookupScheme returns the scheme of path when it is a url of a registered
+// scheme.
+func lookupScheme(path string) (*url.URL, sinkScheme, bool) {
+	if !strings.Contains(path, "://") {
+		return nil, sinkScheme{}, false
+	}
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, sinkScheme{}, false
+	}
+	scheme, ok := _sinkSchemes[u.Scheme]
+
+	return u, scheme, ok
+}
+
+// sinkSet holds the sinks of a logger keyed by their path and the options they
+// were opened with, so that a rebuilt logger can keep the unchanged ones.
+type sinkSet map[string]*sink
+
+// closeExcept closes the sinks of s which are not part of keep.
+func (s sinkSet) closeExcept(keep sinkSet) error {
+	var errs []error
+	for _, wrappers := range []bool{true, false} {
+		for key, snk := range s {
+			if _, ok := keep[key]; ok || snk.wrapper != wrappers {
+				continue
+			}
+			if err := snk.close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return multierr.Combine(errs...)
+}
+
+// closeSpool closes and removes the sink of s whose spool is in dir, so that
+// another sink can open it.
+func (s sinkSet) closeSpool(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	for key, snk := range s {
+		if snk.spoolDir == dir {
+			delete(s, key)
+
+			return snk.close()
 		}
 	}
+
+	return nil
+}
+
+// asyncSyncer wraps w with an asyncWriter when opts.Async is set. The writer
+// is added to sinks so that it is closed together with the logger.
+func asyncSyncer(w zapcore.WriteSyncer, opts *Options, sinks sinkSet) zapcore.WriteSyncer {
+	if !opts.Async || w == nil {
+		return w
+	}
+	a := newAsyncWriter(
+		w,
+		opts.AsyncQueueSize,
+		opts.AsyncOverflow,
+		time.Duration(opts.AsyncFlushIntervalInMS)*time.Millisecond,
+	)
+	sinks[fmt.Sprintf("async:%p", a)] = &sink{WriteSyncer: a, close: a.Close, wrapper: true}
+
+	return a
+}
+
+func sinkKey(path string, options rotationOptions, opts *Options) string {
+	if _, ok := _stdouts[path]; ok {
+		return path
+	}
+	if u, scheme, ok := lookupScheme(path); ok {
+		spool, _ := spoolOf(u, opts)
+
+		return fmt.Sprintf("%s?%+v&%+v", path, scheme.config(opts), spool)
+	}
+
+	return fmt.Sprintf("%s?%+v", path, options)
+}
+
+// buildWriteSyncer combines the sinks of paths into one WriteSyncer, it is nil
+// when there are none. Sinks building a core of their own are returned apart.
+// Sinks are taken from sinks or prev when already opened with the same
+// options, new ones are added to sinks.
+func buildWriteSyncer(
+	paths []string,
+	rotation rotationPolicy,
+	opts *Options,
+	sinks sinkSet,
+	prev sinkSet,
+) (zapcore.WriteSyncer, []*sink, error) {
+	var res []zapcore.WriteSyncer
+	var coreSinks []*sink
 	var errs []error
 	for _, p := range paths {
-		if _, ok := _stdouts[p]; ok {
-			w, closeFunc, err := zap.Open(p)
-			if err != nil {
-				errs = append(errs, err)
+		options := rotation.forPath(p)
+		key := sinkKey(p, options, opts)
+		snk, ok := sinks[key]
+		if !ok {
+			snk, ok = prev[key]
+		}
+		if !ok {
+			// the spool of a sink opened with other options is handed over
+			// to the new sink, a spool is never open twice.
+			dir := spoolDirOf(p, opts)
+			if err := prev.closeSpool(dir); err != nil {
+				errs = append(errs, fmt.Errorf("hand over spool of %q: %w", p, err))
 
 				continue
 			}
-			closers = append(closers, closeFunc)
-			res = append(res, w)
+			var err error
+			if snk, err = openSink(p, options, opts); err != nil {
+				errs = append(errs, fmt.Errorf("open %q: %w", p, err))
+
+				continue
+			}
+			snk.spoolDir = dir
+		}
+		sinks[key] = snk
+		if snk.newCore != nil {
+			coreSinks = append(coreSinks, snk)
 		} else {
-			// add roration for file logs
-			w := zapcore.AddSync(&lumberjack.Logger{
-				Filename:   p,
-				MaxSize:    options.maxSize,
-				MaxBackups: options.maxBackups,
-				MaxAge:     options.maxAge,
-				Compress:   options.compress,
-			})
-			res = append(res, w)
+			res = append(res, snk)
 		}
 	}
 
 	if len(errs) != 0 {
-		closeAll()
+		return nil, nil, multierr.Combine(errs...)
+	}
+	if len(res) == 0 {
+		return nil, coreSinks, nil
+	}
+
+	return zap.CombineWriteSyncers(res...), coreSinks, nil
+}
 
-		return nil, fmt.Errorf("build rotate options has err: %+v", errs)
+func openSink(path string, options rotationOptions, opts *Options) (*sink, error) {
+	if u, scheme, ok := lookupScheme(path); ok {
+		return scheme.open(u, opts)
+	}
+	if strings.Contains(path, "://") {
+		return nil, errors.New("unsupported output path scheme")
+	}
+
+	if _, ok := _stdouts[path]; ok {
+		w, closeFunc, err := zap.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return &sink{WriteSyncer: w, close: func() error {
+			closeFunc()
+
+			return nil
+		}}, nil
+	}
+
+	if options.external {
+		w, err := openReopenFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return &sink{WriteSyncer: w, close: w.Close}, nil
+	}
+
+	if options.interval != "" {
+		w := newTimeRotator(path, options)
+		if _, err := w.Write(nil); err != nil {
+			return nil, err
+		}
+
+		return &sink{WriteSyncer: w, close: w.Close}, nil
+	}
+
+	// add roration for file logs
+	w := &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    options.maxSize,
+		MaxBackups: options.maxBackups,
+		MaxAge:     options.maxAge,
+		Compress:   options.compress,
+		LocalTime:  options.localTime,
+	}
+	// lumberjack opens the file lazily, an empty write reports a bad path now.
+	if _, err := w.Write(nil); err != nil {
+		return nil, err //nolint: wrapcheck // wrapped by the caller.
 	}
 
-	return zap.CombineWriteSyncers(res...), nil
+	return &sink{WriteSyncer: zapcore.AddSync(w), close: w.Close}, nil
 }
 
 func encoderConfigFromOpts(opts *Options) zapcore.EncoderConfig {
-	encodeLevel := zapcore.CapitalLevelEncoder
-	if opts.Format == consoleFormat && opts.EnableColor {
-		encodeLevel = zapcore.CapitalColorLevelEncoder
-	}
+	enc := opts.Encoder
+	keys := enc.keys()
 	encoderConfig := zapcore.EncoderConfig{
-		TimeKey:        "time",
-		LevelKey:       "level",
-		NameKey:        "logger",
-		CallerKey:      "caller",
-		MessageKey:     "msg",
-		StacktraceKey:  "stacktrace",
+		TimeKey:        keys[0],
+		LevelKey:       keys[1],
+		NameKey:        keys[2],
+		CallerKey:      keys[3],
+		MessageKey:     keys[4],
+		StacktraceKey:  keys[5],
 		LineEnding:     zapcore.DefaultLineEnding,
-		EncodeLevel:    encodeLevel,
-		EncodeTime:     timeEncoder,
-		EncodeDuration: milliSecondsDurationEncoder,
+		EncodeLevel:    enc.levelEncoder(opts.Format == consoleFormat && opts.EnableColor),
+		EncodeTime:     enc.timeEncoder(),
+		EncodeDuration: enc.durationEncoder(),
 		EncodeCaller:   zapcore.ShortCallerEncoder,
 	}
 
 	return encoderConfig
 }
 
+// jsonEncoderFromOpts returns the encoder of the remote output paths sending
+// JSON, which keeps the ecs format.
+func jsonEncoderFromOpts(opts *Options) zapcore.Encoder {
+	if opts.Format == ecsFormat {
+		return newECSEncoder(encoderConfigFromOpts(opts))
+	}
+
+	return zapcore.NewJSONEncoder(encoderConfigFromOpts(opts))
+}
+
 func buildZapOptions(cfg zap.Config, errSink zapcore.WriteSyncer) []zap.Option {
 	opts := []zap.Option{zap.ErrorOutput(errSink)}
 
@@ -169,42 +481,60 @@ func buildZapOptions(cfg zap.Config, errSink zapcore.WriteSyncer) []zap.Option {
 	return opts
 }
 
-// newTee return wrapped logger and raw zap logger.
-func newTee(topts []teeOption, encoder zapcore.Encoder, opts ...zap.Option) (*logger, *zap.Logger) {
-	cores := make([]zapcore.Core, len(topts))
-	for i, topt := range topts {
-		if topt.w == nil {
-			panic("the writer is nil")
+// newTee return wrapped logger and raw zap logger. Entries below level are
+// dropped by the wrapped logger before reaching any of the tee cores, the raw
+// logger does not filter them.
+func newTee(
+	topts []teeOption,
+	encoder zapcore.Encoder,
+	level zap.AtomicLevel,
+	opts ...zap.Option,
+) (*logger, *zap.Logger) {
+	cores := make([]zapcore.Core, 0, len(topts))
+	for _, topt := range topts {
+		// w is nil when every path of the tee builds its own core.
+		if topt.w != nil {
+			core := zapcore.NewCore(
+				encoder,
+				topt.w,
+				topt.enabler,
+			)
+			cores = append(cores, core)
 		}
-		core := zapcore.NewCore(
-			encoder,
-			topt.w,
-			topt.enabler,
-		)
-		cores[i] = core
-	}
-	zapLogger := zap.New(zapcore.NewTee(cores...), opts...)
+		for _, snk := range topt.coreSinks {
+			cores = append(cores, snk.newCore(encoder.Clone(), topt.enabler))
+		}
+	}
+	levels := newLevels(level)
+	raw := zap.New(zapcore.NewTee(cores...), opts...)
+	zapLogger := raw.WithOptions(
+		zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return newLevelCore(core, levels)
+		}),
+	)
 	res := &logger{
 		zapLogger: zapLogger,
+		levels:    levels,
 		infoLogger: infoLogger{
 			log:   zapLogger,
 			level: zap.InfoLevel,
 		},
 	}
 
-	return res, zapLogger
+	return res, raw
 }
 
-func normalLogOpts(level zapcore.Level, opts *Options, rotOpts rotationOptions) teeOption {
-	syncer, err := buildWriteSyncer(opts.OutputPaths, rotOpts)
+func normalLogOpts(opts *Options, rotOpts rotationPolicy, sinks sinkSet, prev sinkSet) (teeOption, error) {
+	syncer, coreSinks, err := buildWriteSyncer(opts.OutputPaths, rotOpts, opts, sinks, prev)
 	if err != nil {
-		panic(err)
+		return teeOption{}, fmt.Errorf("output-paths: %w", err)
 	}
 
 	return teeOption{
-		w:       syncer,
-		enabler: levelFunc(level, zapcore.WarnLevel),
-	}
+		w:         syncer,
+		coreSinks: coreSinks,
+		enabler:   levelFunc(zapcore.DebugLevel, zapcore.WarnLevel),
+	}, nil
 }
 
 func levelFunc(minLevel zapcore.Level, maxLevel zapcore.Level) zap.LevelEnablerFunc {
@@ -222,11 +552,3 @@ func levelFunc(minLevel zapcore.Level, maxLevel zapcore.Level) zap.LevelEnablerF
 		return true
 	}
 }
-
-func maxLevel(x, y zapcore.Level) zapcore.Level {
-	if x < y {
-		return y
-	}
-
-	return x
-}
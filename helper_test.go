@@ -0,0 +1,41 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_buildRotationOpts(t *testing.T) {
+	compress, backups, age := true, 30, 0
+	opts := NewOptions()
+	opts.MaxBackups = 3
+	opts.LocalTime = true
+	opts.Rotations = map[string]RotationOptions{
+		"err.log": {MaxBackups: &backups, Compress: &compress},
+		// zero overrides are applied.
+		"audit.log": {MaxAgeInDays: &age},
+	}
+
+	policy := buildRotationOpts(opts)
+	assert.Equal(t, rotationOptions{
+		maxSize:    100,
+		maxAge:     7,
+		maxBackups: 3,
+		localTime:  true,
+	}, policy.forPath("info.log"))
+	assert.Equal(t, rotationOptions{
+		maxSize:    100,
+		maxAge:     7,
+		maxBackups: 30,
+		compress:   true,
+		localTime:  true,
+	}, policy.forPath("err.log"))
+	assert.Equal(t, rotationOptions{
+		maxSize:    100,
+		maxBackups: 3,
+		localTime:  true,
+	}, policy.forPath("audit.log"))
+	assert.Equal(t, _defaultRotateOpts, buildRotationOpts(nil).forPath("info.log"))
+	assert.Equal(t, _defaultRotateOpts, buildRotationOpts(&Options{}).forPath("info.log"))
+}
@@ -0,0 +1,248 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	httpNDJSON    = "ndjson"
+	httpJSONArray = "json-array"
+	httpTemplate  = "template"
+)
+
+// nolint: gochecknoinits // register the http output path schemes.
+func init() {
+	scheme := sinkScheme{open: openHTTPSink, config: httpConfig, buffered: true}
+	_sinkSchemes["http"] = scheme
+	_sinkSchemes["https"] = scheme
+}
+
+// HTTPOptions configures the http:// and https:// output paths, which POST
+// the entries encoded as JSON in batches to the url. Credentials of the url
+// are sent with basic auth unless BearerTokenFile is set.
+type HTTPOptions struct {
+	// Format is the body of the requests: ndjson, json-array or template.
+	Format string `json:"format"            mapstructure:"format"`
+	// Template is the text/template of the bodies of the template format. It
+	// is executed with the entries of the batch decoded from JSON, and has a
+	// json function encoding a value as JSON.
+	Template string            `json:"template"          mapstructure:"template"`
+	Headers  map[string]string `json:"headers"           mapstructure:"headers"`
+	// BearerTokenFile is read again whenever it changes.
+	BearerTokenFile string `json:"bearer-token-file" mapstructure:"bearer-token-file"`
+	// MaxRetries bounds how many times a failed request is sent again, with
+	// an exponential backoff, before its batch is rejected.
+	MaxRetries int `json:"max-retries"       mapstructure:"max-retries"`
+	// DeadLetterFile receives the entries of the rejected batches, one per
+	// line. They are dropped when it is not set.
+	DeadLetterFile string       `json:"dead-letter-file"  mapstructure:"dead-letter-file"`
+	Batch          BatchOptions `json:"batch"             mapstructure:"batch"`
+}
+
+func (o HTTPOptions) validate() []error {
+	var errs []error
+	switch o.Format {
+	case "", httpNDJSON, httpJSONArray:
+	case httpTemplate:
+		if _, err := parseHTTPTemplate(o.Template); err != nil {
+			errs = append(errs, err)
+		}
+	default:
+		errs = append(errs, fmt.Errorf("not a valid http format: %q", o.Format))
+	}
+	if o.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("negative http max retries: %d", o.MaxRetries))
+	}
+
+	return append(errs, o.Batch.validate("http")...)
+}
+
+func parseHTTPTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, errors.New("missing template of the http template format")
+	}
+	tmpl, err := template.New("http").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			data, err := json.Marshal(v)
+
+			return string(data), err
+		},
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse http template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+type httpSinkConfig struct {
+	http   HTTPOptions
+	stream streamSinkConfig
+}
+
+func httpConfig(opts *Options) interface{} {
+	return httpSinkConfig{http: opts.HTTP, stream: streamConfig(opts).(streamSinkConfig)}
+}
+
+func openHTTPSink(u *url.URL, opts *Options) (*sink, error) {
+	cfg := opts.HTTP
+	var tmpl *template.Template
+	if cfg.Format == httpTemplate {
+		var err error
+		if tmpl, err = parseHTTPTemplate(cfg.Template); err != nil {
+			return nil, err
+		}
+	}
+	target := httpTarget(u, "/")
+	client, err := newHTTPClient(target, opts)
+	if err != nil {
+		return nil, err
+	}
+	token := &tokenFile{path: cfg.BearerTokenFile}
+	encoder := jsonEncoderFromOpts(opts)
+
+	encode := func(_ zapcore.Encoder, ent zapcore.Entry, fields []zapcore.Field) ([]byte, error) {
+		buf, err := encoder.EncodeEntry(ent, fields)
+		if err != nil {
+			return nil, err //nolint: wrapcheck // keep the error of the encoder.
+		}
+		defer buf.Free()
+
+		return append([]byte(nil), bytes.TrimRight(buf.Bytes(), "\n")...), nil
+	}
+
+	post := func(batch [][]byte) error {
+		body, contentType, err := httpBody(cfg.Format, tmpl, batch)
+		if err != nil {
+			return &permanentError{err: err}
+		}
+		req, err := http.NewRequest(http.MethodPost, target.String(), bytes.NewReader(body))
+		if err != nil {
+			return &permanentError{err: fmt.Errorf("build http request: %w", err)}
+		}
+		req.Header.Set("Content-Type", contentType)
+		for k, v := range cfg.Headers {
+			req.Header.Set(k, v)
+		}
+		if cfg.BearerTokenFile != "" {
+			bearer, err := token.get()
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		} else if u.User != nil {
+			password, _ := u.User.Password()
+			req.SetBasicAuth(u.User.Username(), password)
+		}
+		_, err = doRequest(client, req)
+
+		return err
+	}
+
+	// the batches failing with retryable errors are sent again up to
+	// MaxRetries times by the buffered writer.
+	send := func(batch [][]byte) error {
+		err := post(batch)
+		var permanent *permanentError
+		if err == nil || errors.As(err, &permanent) {
+			return err
+		}
+
+		return &retryError{err: err, retry: batch}
+	}
+	policy := cfg.Batch.policy()
+	policy.retries = cfg.MaxRetries
+	policy.reject = func(batch [][]byte, err error) error {
+		return deadLetter(cfg.DeadLetterFile, batch, err)
+	}
+
+	return newBatchSink(u, opts, policy, encode, send)
+}
+
+// httpBody returns the body of the requests of format holding batch, and its
+// content type.
+func httpBody(format string, tmpl *template.Template, batch [][]byte) ([]byte, string, error) {
+	switch format {
+	case httpJSONArray:
+		return append(append([]byte("["), bytes.Join(batch, []byte(","))...), ']'), "application/json", nil
+	case httpTemplate:
+		entries := make([]map[string]interface{}, 0, len(batch))
+		for _, record := range batch {
+			var entry map[string]interface{}
+			if err := json.Unmarshal(record, &entry); err != nil {
+				return nil, "", fmt.Errorf("decode entry: %w", err)
+			}
+			entries = append(entries, entry)
+		}
+		var body bytes.Buffer
+		if err := tmpl.Execute(&body, entries); err != nil {
+			return nil, "", fmt.Errorf("execute http template: %w", err)
+		}
+
+		return body.Bytes(), "text/plain; charset=utf-8", nil
+	default:
+		return append(bytes.Join(batch, []byte("\n")), '\n'), "application/x-ndjson", nil
+	}
+}
+
+// deadLetter appends the entries of a batch rejected with err to the file
+// name, err is returned when there is no such file.
+func deadLetter(name string, batch [][]byte, err error) error {
+	if name == "" {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return fmt.Errorf("create dead letter dir: %w", err)
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open dead letter file: %w", err)
+	}
+	_, err = f.Write(append(bytes.Join(batch, []byte("\n")), '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write dead letter file: %w", err)
+	}
+
+	return nil
+}
+
+// tokenFile reads a token from a file again whenever the file changes.
+type tokenFile struct {
+	path    string
+	modTime time.Time
+	size    int64
+	token   string
+}
+
+func (t *tokenFile) get() (string, error) {
+	info, err := os.Stat(t.path)
+	if err != nil {
+		return "", fmt.Errorf("stat token file: %w", err)
+	}
+	if info.ModTime().Equal(t.modTime) && info.Size() == t.size && t.token != "" {
+		return t.token, nil
+	}
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return "", fmt.Errorf("read token file: %w", err)
+	}
+	t.token = strings.TrimSpace(string(data))
+	t.modTime, t.size = info.ModTime(), info.Size()
+
+	return t.token, nil
+}
@@ -0,0 +1,165 @@
+package log_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/huanghe314/log"
+)
+
+type httpRequest struct {
+	Header http.Header
+	Body   string
+}
+
+// httpServer records the requests it receives, answering the first ones with
+// the given status codes.
+type httpServer struct {
+	mu       sync.Mutex
+	statuses []int
+	reqs     []httpRequest
+}
+
+func (s *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	s.reqs = append(s.reqs, httpRequest{Header: r.Header, Body: string(body)})
+	if len(s.statuses) != 0 {
+		w.WriteHeader(s.statuses[0])
+		s.statuses = s.statuses[1:]
+	}
+}
+
+func Test_HTTP_NDJSON(t *testing.T) {
+	server := &httpServer{statuses: []int{http.StatusServiceUnavailable}}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+	token := filepath.Join(t.TempDir(), "token")
+	assert.Nil(t, os.WriteFile(token, []byte("first\n"), 0o600))
+
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{srv.URL + "/ingest"}
+	opts.HTTP.Headers = map[string]string{"X-Source": "app"}
+	opts.HTTP.BearerTokenFile = token
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	logger.Infow("first", "user", "alice")
+	logger.Info("second")
+	// the batch is sent again after 503.
+	logger.Flush()
+	assert.Nil(t, os.WriteFile(token, []byte("rotated\n"), 0o600))
+	logger.Info("third")
+	logger.Flush()
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Len(t, server.reqs, 3)
+	assert.Equal(t, server.reqs[0], server.reqs[1])
+	req := server.reqs[1]
+	assert.Equal(t, "application/x-ndjson", req.Header.Get("Content-Type"))
+	assert.Equal(t, "app", req.Header.Get("X-Source"))
+	assert.Equal(t, "Bearer first", req.Header.Get("Authorization"))
+	lines := strings.Split(strings.TrimSuffix(req.Body, "\n"), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"msg":"first","user":"alice"`)
+	assert.Contains(t, lines[1], `"msg":"second"`)
+	assert.Equal(t, "Bearer rotated", server.reqs[2].Header.Get("Authorization"))
+}
+
+func Test_HTTP_Formats(t *testing.T) {
+	server := &httpServer{}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{srv.URL}
+	opts.HTTP.Format = "json-array"
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	logger.Info("first")
+	logger.Info("second")
+	closeFunc()
+
+	opts.HTTP.Format = "template"
+	opts.HTTP.Template = `{{range .}}{{.level}} {{.msg}} {{json .user}};{{end}}`
+	logger, closeFunc, err = log.New(opts)
+	assert.Nil(t, err)
+	logger.Infow("third", "user", "bob")
+	closeFunc()
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Len(t, server.reqs, 2)
+	assert.Equal(t, "application/json", server.reqs[0].Header.Get("Content-Type"))
+	assert.Regexp(t, `^\[\{.*"msg":"first"\},\{.*"msg":"second"\}\]$`, server.reqs[0].Body)
+	assert.Equal(t, `INFO third "bob";`, server.reqs[1].Body)
+
+	opts.HTTP.Template = "{{"
+	assert.NotEmpty(t, opts.Validate())
+}
+
+func Test_HTTP_DeadLetter(t *testing.T) {
+	server := &httpServer{statuses: []int{http.StatusBadRequest, 500, 500}}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+	deadLetter := filepath.Join(t.TempDir(), "dead", "letter.log")
+
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{srv.URL}
+	opts.HTTP.MaxRetries = 1
+	opts.HTTP.DeadLetterFile = deadLetter
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	dropped := log.DroppedEntries()
+	logger.Info("rejected")
+	logger.Flush()
+	logger.Info("failed")
+	// the batch fails again after its single retry.
+	logger.Flush()
+	logger.Info("accepted")
+	logger.Flush()
+
+	data, err := os.ReadFile(deadLetter)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"msg":"rejected"`)
+	assert.Contains(t, lines[1], `"msg":"failed"`)
+	assert.Equal(t, uint64(0), log.DroppedEntries()-dropped)
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Len(t, server.reqs, 4)
+}
+
+func Test_HTTP_Close(t *testing.T) {
+	server := &httpServer{statuses: []int{503, 503, 503, 503, 503, 503}}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{srv.URL}
+	opts.Stream.FlushTimeoutInMS = 100
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+
+	logger.Info("unavailable")
+	// the retries of the failing batch do not hold up the close.
+	start := time.Now()
+	assert.NotNil(t, closeFunc())
+	assert.Less(t, time.Since(start), time.Second)
+}
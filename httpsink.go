@@ -0,0 +1,149 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const httpSendTimeout = 30 * time.Second
+
+// BatchOptions groups the entries of a remote output path into the requests
+// sent to it. A request is sent once it holds MaxEntries or MaxSizeInKB, or
+// WaitInMS after its first entry was logged. Zero fields are not limits.
+type BatchOptions struct {
+	MaxEntries  int `json:"max-entries"    mapstructure:"max-entries"`
+	MaxSizeInKB int `json:"max-size-in-kb" mapstructure:"max-size-in-kb"`
+	WaitInMS    int `json:"wait-in-ms"     mapstructure:"wait-in-ms"`
+}
+
+func (o BatchOptions) validate(name string) []error {
+	var errs []error
+	if o.MaxEntries < 0 {
+		errs = append(errs, fmt.Errorf("negative %s batch max entries: %d", name, o.MaxEntries))
+	}
+	if o.MaxSizeInKB < 0 {
+		errs = append(errs, fmt.Errorf("negative %s batch max size in KB: %d", name, o.MaxSizeInKB))
+	}
+	if o.WaitInMS < 0 {
+		errs = append(errs, fmt.Errorf("negative %s batch wait: %d", name, o.WaitInMS))
+	}
+
+	return errs
+}
+
+func (o BatchOptions) policy() batchPolicy {
+	return batchPolicy{
+		entries: o.MaxEntries,
+		bytes:   o.MaxSizeInKB * kilobyte,
+		wait:    time.Duration(o.WaitInMS) * time.Millisecond,
+	}
+}
+
+// httpTarget returns the url a remote output path of scheme name+http or
+// name+https sends to, defaultPath is used when the output path has none.
+func httpTarget(u *url.URL, defaultPath string) *url.URL {
+	target := *u
+	target.Scheme = u.Scheme[strings.LastIndex(u.Scheme, "+")+1:]
+	target.User = nil
+	if target.Path == "" || target.Path == "/" {
+		target.Path = defaultPath
+	}
+
+	return &target
+}
+
+// newHTTPClient returns the client of a remote output path, using the TLS
+// options for https.
+func newHTTPClient(target *url.URL, opts *Options) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if target.Scheme == "https" {
+		cfg, err := opts.TLS.config()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = cfg
+	}
+
+	return &http.Client{Transport: transport, Timeout: httpSendTimeout}, nil
+}
+
+// gzipBody compresses body.
+func gzipBody(body []byte) []byte {
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	_, _ = gz.Write(body)
+	_ = gz.Close()
+
+	return b.Bytes()
+}
+
+// doRequest sends req and returns the body of its response. Errors of 429 and
+// 5xx responses and of the transport can be retried, the other errors are
+// permanent.
+func doRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err //nolint: wrapcheck // keep the error of the client.
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response of %s: %w", req.URL.Redacted(), err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return body, nil
+	}
+
+	err = fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Redacted(), resp.Status, bytes.TrimSpace(body))
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return nil, err
+	}
+
+	return nil, &permanentError{err: err}
+}
+
+// newBatchSink returns a sink encoding every entry with encode and sending the
+// buffered entries in batches with send.
+func newBatchSink(
+	u *url.URL,
+	opts *Options,
+	policy batchPolicy,
+	encode func(enc zapcore.Encoder, ent zapcore.Entry, fields []zapcore.Field) ([]byte, error),
+	send func(batch [][]byte) error,
+) (*sink, error) {
+	cfg, _ := streamConfig(opts).(streamSinkConfig)
+	buffer, err := openBuffer(u, opts, cfg.stream.BufferSizeInKB*kilobyte)
+	if err != nil {
+		return nil, err
+	}
+	w := newBufferedWriter(buffer, send, policy, time.Duration(cfg.stream.FlushTimeoutInMS)*time.Millisecond)
+	newCore := func(enc zapcore.Encoder, enabler zapcore.LevelEnabler) zapcore.Core {
+		return &entryCore{
+			LevelEnabler: enabler,
+			write: func(ent zapcore.Entry, fields []zapcore.Field) error {
+				record, err := encode(enc, ent, fields)
+				if err != nil {
+					return err
+				}
+				_, err = w.Write(record)
+
+				return err
+			},
+			sync: w.Sync,
+		}
+	}
+
+	return &sink{
+		WriteSyncer: coreWriter{newCore(jsonEncoderFromOpts(opts), zapcore.DebugLevel)},
+		close:       w.Close,
+		newCore:     newCore,
+	}, nil
+}
@@ -0,0 +1,232 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultJournalSocket = "/run/systemd/journal/socket"
+	// journalFieldPrefix is prepended to the names of the fields colliding
+	// with the fields set by the writer or by journald.
+	journalFieldPrefix = "FIELD_"
+)
+
+// _journalReserved are the fields set by the writer and the well-known user
+// journal fields, which the fields of the entries cannot override.
+var _journalReserved = map[string]bool{
+	"MESSAGE": true, "MESSAGE_ID": true, "PRIORITY": true, "CODE_FILE": true, "CODE_LINE": true,
+	"CODE_FUNC": true, "ERRNO": true, "INVOCATION_ID": true, "USER_INVOCATION_ID": true,
+	"SYSLOG_FACILITY": true, "SYSLOG_IDENTIFIER": true, "SYSLOG_PID": true, "SYSLOG_TIMESTAMP": true,
+	"SYSLOG_RAW": true, "DOCUMENTATION": true, "TID": true, "UNIT": true, "USER_UNIT": true,
+	"LOGGER": true, "STACKTRACE": true,
+}
+
+// nolint: gochecknoinits // register the journald output path scheme.
+func init() {
+	_sinkSchemes["journald"] = sinkScheme{open: openJournalSink, config: journalIdentifier}
+}
+
+// journalIdentifier returns the SYSLOG_IDENTIFIER of the entries, the name of
+// the logger or else the program name.
+func journalIdentifier(opts *Options) interface{} {
+	if opts.Name != "" {
+		return opts.Name
+	}
+	if len(os.Args) > 0 {
+		return filepath.Base(os.Args[0])
+	}
+
+	return ""
+}
+
+// journalWriter sends entries to journald over its native protocol, one
+// datagram per entry. Entries larger than the datagram limit of the socket are
+// passed in a sealed memfd on Linux, elsewhere they are dropped.
+type journalWriter struct {
+	identifier string
+
+	mu   sync.Mutex
+	conn *net.UnixConn
+	addr *net.UnixAddr
+}
+
+// openJournalSink opens journald:// which writes to the socket of journald, or
+// journald:///path/to/socket.
+func openJournalSink(u *url.URL, opts *Options) (*sink, error) {
+	path := u.Path
+	if path == "" {
+		path = defaultJournalSocket
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("journald socket: %w", err)
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("open journald socket: %w", err)
+	}
+	identifier, _ := journalIdentifier(opts).(string)
+	w := &journalWriter{
+		identifier: identifier,
+		conn:       conn,
+		addr:       &net.UnixAddr{Name: path, Net: "unixgram"},
+	}
+
+	return &sink{
+		WriteSyncer: w,
+		close:       w.Close,
+		newCore: func(_ zapcore.Encoder, enabler zapcore.LevelEnabler) zapcore.Core {
+			return &entryCore{
+				LevelEnabler: enabler,
+				write:        w.writeEntry,
+				sync:         w.Sync,
+			}
+		},
+	}, nil
+}
+
+// Write sends p as the message of an info entry.
+func (w *journalWriter) Write(p []byte) (int, error) {
+	if err := w.writeEntry(zapcore.Entry{Level: zapcore.InfoLevel, Time: time.Now(), Message: string(p)}, nil); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (w *journalWriter) writeEntry(ent zapcore.Entry, fields []zapcore.Field) error {
+	var b bytes.Buffer
+	appendJournalField(&b, "MESSAGE", strings.TrimRight(ent.Message, "\n"))
+	appendJournalField(&b, "PRIORITY", strconv.Itoa(syslogSeverity(ent.Level)))
+	appendJournalField(&b, "SYSLOG_IDENTIFIER", w.identifier)
+	if ent.LoggerName != "" {
+		appendJournalField(&b, "LOGGER", ent.LoggerName)
+	}
+	if ent.Caller.Defined {
+		appendJournalField(&b, "CODE_FILE", ent.Caller.File)
+		appendJournalField(&b, "CODE_LINE", strconv.Itoa(ent.Caller.Line))
+		if ent.Caller.Function != "" {
+			appendJournalField(&b, "CODE_FUNC", ent.Caller.Function)
+		}
+	}
+	if ent.Stack != "" {
+		appendJournalField(&b, "STACKTRACE", ent.Stack)
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	keys := make([]string, 0, len(enc.Fields))
+	for k := range enc.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		name := journalFieldName(k)
+		if name == "" {
+			continue
+		}
+		appendJournalField(&b, name, fieldString(enc.Fields[k]))
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return fmt.Errorf("write to journald: socket already closed")
+	}
+	if _, err := w.conn.WriteToUnix(b.Bytes(), w.addr); err != nil {
+		if journalTooLarge(err) {
+			return w.writeLarge(b.Bytes())
+		}
+
+		return fmt.Errorf("write to journald: %w", err)
+	}
+
+	return nil
+}
+
+// journalFieldName converts key to a journal field name: upper case letters,
+// digits and underscores, not starting with an underscore or a digit. So the
+// trusted fields starting with an underscore cannot be set, and the reserved
+// fields get the FIELD_ prefix.
+func journalFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, c := range name {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			name[i] = '_'
+		}
+	}
+	field := strings.TrimLeft(string(name), "_0123456789")
+	if _journalReserved[field] {
+		return journalFieldPrefix + field
+	}
+
+	return field
+}
+
+// fieldString formats a field value of a zapcore.MapObjectEncoder.
+func fieldString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	case error:
+		return v.Error()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(data)
+}
+
+// appendJournalField appends a field in the native protocol of journald:
+// NAME=value followed by a newline, or for values holding newlines NAME, a
+// newline, the little endian 64 bit length of the value, the value and a
+// newline.
+func appendJournalField(b *bytes.Buffer, name, value string) {
+	b.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+
+		return
+	}
+	b.WriteByte('\n')
+	_ = binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+func (w *journalWriter) Sync() error {
+	return nil
+}
+
+func (w *journalWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+
+	return err //nolint: wrapcheck // keep the error of the socket.
+}
@@ -0,0 +1,41 @@
+//go:build linux
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// journalTooLarge tells whether err is the error of an entry larger than the
+// datagram limit of the socket.
+func journalTooLarge(err error) bool {
+	return errors.Is(err, unix.EMSGSIZE) || errors.Is(err, unix.ENOBUFS)
+}
+
+// writeLarge passes data to journald in a sealed memfd as the native protocol
+// defines for the entries exceeding the datagram limit. It is called with the
+// lock of w held.
+func (w *journalWriter) writeLarge(data []byte) error {
+	fd, err := unix.MemfdCreate("journal-entry", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return fmt.Errorf("write to journald: create memfd: %w", err)
+	}
+	f := os.NewFile(uintptr(fd), "journal-entry")
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("write to journald: write memfd: %w", err)
+	}
+	seals := unix.F_SEAL_SHRINK | unix.F_SEAL_GROW | unix.F_SEAL_WRITE | unix.F_SEAL_SEAL
+	if _, err := unix.FcntlInt(f.Fd(), unix.F_ADD_SEALS, seals); err != nil {
+		return fmt.Errorf("write to journald: seal memfd: %w", err)
+	}
+	if _, _, err := w.conn.WriteMsgUnix(nil, unix.UnixRights(int(f.Fd())), w.addr); err != nil {
+		return fmt.Errorf("write to journald: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+//go:build linux
+
+package log_test
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/huanghe314/log"
+)
+
+func Test_Journald_Memfd(t *testing.T) {
+	dir, err := os.MkdirTemp("", "journal")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{"journald://" + path}
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	// the entry exceeds the datagram limit, it is passed in a memfd.
+	large := strings.Repeat("x", 4<<20)
+	logger.Info(large)
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	oob := make([]byte, syscall.CmsgSpace(4))
+	n, oobn, _, _, err := conn.ReadMsgUnix(nil, oob)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	assert.Nil(t, err)
+	assert.Len(t, msgs, 1)
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	assert.Nil(t, err)
+	assert.Len(t, fds, 1)
+	f := os.NewFile(uintptr(fds[0]), "memfd")
+	defer f.Close()
+	// the memfd shares its offset with the writer, which is at its end.
+	_, err = f.Seek(0, io.SeekStart)
+	assert.Nil(t, err)
+	data, err := io.ReadAll(f)
+	assert.Nil(t, err)
+	assert.True(t, parseJournal(t, data)["MESSAGE"] == large, "message of the memfd")
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"syscall"
+)
+
+// journalTooLarge tells whether err is the error of an entry larger than the
+// datagram limit of the socket.
+func journalTooLarge(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE)
+}
+
+// writeLarge drops the entries exceeding the datagram limit, which only Linux
+// can pass in a memfd.
+func (w *journalWriter) writeLarge(data []byte) error {
+	atomic.AddUint64(&_droppedEntries, 1)
+
+	return fmt.Errorf("write to journald: entry of %d bytes exceeds the datagram limit", len(data))
+}
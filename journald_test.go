@@ -0,0 +1,94 @@
+package log_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/huanghe314/log"
+)
+
+// parseJournal parses a datagram of the native protocol of journald.
+func parseJournal(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	fields := map[string]string{}
+	for len(data) > 0 {
+		line := bytes.IndexByte(data, '\n')
+		if !assert.True(t, line > 0, "missing newline") {
+			return fields
+		}
+		if eq := bytes.IndexByte(data[:line], '='); eq >= 0 {
+			fields[string(data[:eq])] = string(data[eq+1 : line])
+			data = data[line+1:]
+
+			continue
+		}
+		name := string(data[:line])
+		data = data[line+1:]
+		size := binary.LittleEndian.Uint64(data[:8])
+		fields[name] = string(data[8 : 8+size])
+		assert.Equal(t, byte('\n'), data[8+size])
+		data = data[9+size:]
+	}
+
+	return fields
+}
+
+func Test_Journald(t *testing.T) {
+	// unix socket paths are limited in length, t.TempDir may be too long.
+	dir, err := os.MkdirTemp("", "journal")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "socket")
+	conn, err := net.ListenPacket("unixgram", path)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	opts := log.NewOptions()
+	opts.Name = "app"
+	opts.OutputPaths = []string{"journald://" + path}
+	opts.ErrorOutputPaths = opts.OutputPaths
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	read := func() map[string]string {
+		buf := make([]byte, 64*1024)
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		assert.Nil(t, err)
+
+		return parseJournal(t, buf[:n])
+	}
+
+	logger.WithName("db").WithValues("request-id", 42).Infow("hello", "user", "alice",
+		"message", "shadow", "_pid", 1)
+	fields := read()
+	assert.Equal(t, "hello", fields["MESSAGE"])
+	assert.Equal(t, "shadow", fields["FIELD_MESSAGE"])
+	assert.Equal(t, "1", fields["PID"])
+	assert.NotContains(t, fields, "SYSLOG_TIMESTAMP")
+	assert.Equal(t, "6", fields["PRIORITY"])
+	assert.Equal(t, "app", fields["SYSLOG_IDENTIFIER"])
+	assert.Equal(t, "db", fields["LOGGER"])
+	assert.Equal(t, "42", fields["REQUEST_ID"])
+	assert.Equal(t, "alice", fields["USER"])
+
+	logger.Errorw("failed", "detail", "line 1\nline 2")
+	fields = read()
+	assert.Equal(t, "3", fields["PRIORITY"])
+	assert.Equal(t, "line 1\nline 2", fields["DETAIL"])
+}
+
+func Test_Journald_MissingSocket(t *testing.T) {
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{"journald://" + filepath.Join(t.TempDir(), "missing")}
+	_, _, err := log.New(opts)
+	assert.NotNil(t, err)
+}
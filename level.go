@@ -0,0 +1,232 @@
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// levels holds the level of a root logger together with the levels set for
+// its named loggers. The named levels are copied on write so that the hot
+// logging path never takes a lock.
+type levels struct {
+	root  zap.AtomicLevel
+	mu    sync.Mutex
+	named atomic.Value // map[string]zap.AtomicLevel
+}
+
+func newLevels(root zap.AtomicLevel) *levels {
+	l := &levels{root: root}
+	l.named.Store(map[string]zap.AtomicLevel{})
+
+	return l
+}
+
+func (l *levels) namedLevels() map[string]zap.AtomicLevel {
+	return l.named.Load().(map[string]zap.AtomicLevel)
+}
+
+// levelOf returns the level of the logger with the given name. The level set
+// for the longest dot separated prefix of name wins, the root level is used
+// when no prefix has a level of its own.
+func (l *levels) levelOf(name string) zapcore.Level {
+	named := l.namedLevels()
+	for len(named) > 0 && name != "" {
+		if lvl, ok := named[name]; ok {
+			return lvl.Level()
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+
+	return l.root.Level()
+}
+
+// setLevel changes the level of the named logger, or of the root logger when
+// name is empty.
+func (l *levels) setLevel(name string, level zapcore.Level) {
+	if name == "" {
+		l.root.SetLevel(level)
+
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	old := l.namedLevels()
+	if lvl, ok := old[name]; ok {
+		lvl.SetLevel(level)
+
+		return
+	}
+	named := make(map[string]zap.AtomicLevel, len(old)+1)
+	for k, v := range old {
+		named[k] = v
+	}
+	named[name] = zap.NewAtomicLevelAt(level)
+	l.named.Store(named)
+}
+
+// unsetLevel removes the level of the named logger, which then follows the
+// level of its closest named parent again.
+func (l *levels) unsetLevel(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	old := l.namedLevels()
+	if _, ok := old[name]; !ok {
+		return
+	}
+	named := make(map[string]zap.AtomicLevel, len(old))
+	for k, v := range old {
+		if k != name {
+			named[k] = v
+		}
+	}
+	l.named.Store(named)
+}
+
+// reload applies the levels of next which differ from prev, the levels set at
+// runtime for anything next leaves unchanged are kept. Named levels which are
+// no longer configured are removed. A nil prev resets every level to next.
+func (l *levels) reload(prev, next *Options) {
+	if prev == nil || prev.Level != next.Level {
+		var lvl zapcore.Level
+		if err := lvl.UnmarshalText([]byte(next.Level)); err != nil {
+			lvl = zapcore.InfoLevel
+		}
+		l.root.SetLevel(lvl)
+	}
+	nextNamed, _ := parseNamedLevels(next.Levels)
+	prevNamed := map[string]zapcore.Level{}
+	if prev != nil {
+		prevNamed, _ = parseNamedLevels(prev.Levels)
+	}
+	for _, name := range l.names() {
+		_, configured := prevNamed[name]
+		if _, ok := nextNamed[name]; !ok && (prev == nil || configured) {
+			l.unsetLevel(name)
+		}
+	}
+	for name, lvl := range nextNamed {
+		if old, ok := prevNamed[name]; !ok || old != lvl {
+			l.setLevel(name, lvl)
+		}
+	}
+}
+
+// names returns the sorted names of the loggers which have their own level.
+func (l *levels) names() []string {
+	named := l.namedLevels()
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// levelCore filters entries below a runtime adjustable level before they reach
+// the wrapped core, so that changing the level never requires rebuilding sinks.
+type levelCore struct {
+	zapcore.Core
+	levels *levels
+	name   string
+}
+
+func newLevelCore(core zapcore.Core, levels *levels) zapcore.Core {
+	return &levelCore{Core: core, levels: levels}
+}
+
+// namedCore replaces the name used to look up the level of a levelCore, it
+// leaves any other core untouched.
+func namedCore(core zapcore.Core, name string) zapcore.Core {
+	if c, ok := core.(*levelCore); ok {
+		return &levelCore{Core: c.Core, levels: c.levels, name: name}
+	}
+
+	return core
+}
+
+func (c *levelCore) Enabled(lvl zapcore.Level) bool {
+	return lvl >= c.levels.levelOf(c.name) && c.Core.Enabled(lvl)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), levels: c.levels, name: c.name}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level < c.levels.levelOf(c.name) {
+		return ce
+	}
+
+	return c.Core.Check(ent, ce)
+}
+
+// lowestEnabledLevel returns the lowest level enabled by core.
+func lowestEnabledLevel(core zapcore.Core) zapcore.Level {
+	for lvl := zapcore.DebugLevel; lvl < zapcore.FatalLevel; lvl++ {
+		if core.Enabled(lvl) {
+			return lvl
+		}
+	}
+
+	return zapcore.FatalLevel
+}
+
+// parseNamedLevels parses the per logger name levels of Options.Levels.
+func parseNamedLevels(named map[string]string) (map[string]zapcore.Level, []error) {
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	res := make(map[string]zapcore.Level, len(named))
+	for _, name := range names {
+		text := named[name]
+		if name == "" {
+			errs = append(errs, fmt.Errorf("empty logger name in %s", flagLevels))
+
+			continue
+		}
+		var lvl zapcore.Level
+		if err := lvl.UnmarshalText([]byte(text)); err != nil {
+			errs = append(errs, fmt.Errorf("invalid level for logger %q: %w", name, err))
+
+			continue
+		}
+		res[name] = lvl
+	}
+
+	return res, errs
+}
+
+// joinName joins logger name segments the same way as zap.Logger.Named.
+func joinName(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	if name == "" {
+		return parent
+	}
+
+	return parent + "." + name
+}
+
+// SetLevel changes the minimum enabled level of the global logger at runtime.
+// The new level takes effect immediately for every sink and for the klog bridge.
+func SetLevel(level Level) { _logger.Load().SetLevel(level) }
+
+// GetLevel returns the minimum enabled level of the global logger.
+func GetLevel() Level { return _logger.Load().GetLevel() }
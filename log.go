@@ -21,9 +21,13 @@ package log
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"os"
 	"sync"
+	"sync/atomic"
 
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
@@ -90,22 +94,71 @@ type Logger interface {
 	// WithContext returns a copy of context in which the log value is set.
 	WithContext(ctx context.Context) context.Context
 
+	// SetLevel changes the minimum enabled level at runtime. On the root
+	// logger the change is shared with every derived logger, on a named
+	// logger it applies to that name and to the descendants of it which have
+	// no level of their own.
+	SetLevel(level Level)
+
+	// GetLevel returns the minimum enabled level.
+	GetLevel() Level
+
 	// Flush calls the underlying Core's Sync method, flushing any buffered
 	// log entries. Applications should take care to call Sync before exiting.
 	Flush()
 }
 
 var (
-	_logger  *logger
+	_logger  atomicLogger
 	_options *Options
+	_sinks   sinkSet
 	mu       sync.Mutex
+
+	// _core and _errorOutput are the core and the error output of the global
+	// logger and of every logger derived from it, Init swaps what they write
+	// to.
+	_core        = newSwapCore()
+	_errorOutput = &swapSyncer{}
 )
 
+// atomicLogger holds the global logger, it is replaced under mu while other
+// goroutines keep logging through it.
+type atomicLogger struct {
+	v atomic.Value
+}
+
+func (a *atomicLogger) Load() *logger {
+	l, _ := a.v.Load().(*logger)
+
+	return l
+}
+
+func (a *atomicLogger) Store(l *logger) {
+	a.v.Store(l)
+}
+
 type rotationOptions struct {
 	maxSize    int
 	maxAge     int
 	maxBackups int
 	compress   bool
+	localTime  bool
+	interval   string
+	external   bool
+}
+
+// rotationPolicy holds the rotation options of every output path.
+type rotationPolicy struct {
+	defaults rotationOptions
+	paths    map[string]rotationOptions
+}
+
+func (p rotationPolicy) forPath(path string) rotationOptions {
+	if options, ok := p.paths[path]; ok {
+		return options
+	}
+
+	return p.defaults
 }
 
 const (
@@ -119,6 +172,7 @@ var (
 		maxAge:     7,   // retain max 7 days log.
 		maxBackups: 0,
 		compress:   false,
+		localTime:  false,
 	}
 
 	_stdouts = map[string]struct{}{
@@ -128,8 +182,9 @@ var (
 )
 
 type teeOption struct {
-	w       zapcore.WriteSyncer
-	enabler zapcore.LevelEnabler
+	w         zapcore.WriteSyncer
+	coreSinks []*sink
+	enabler   zapcore.LevelEnabler
 }
 
 // nolint: gochecknoinits // need to init a default logger
@@ -138,41 +193,158 @@ func init() {
 }
 
 // Init initializes logger by opts which can be customized by command arguments.
+// The logger is built like New, reusing the sinks of the previous global logger,
+// and klog and the standard library logger are redirected to it. It panics when
+// a sink cannot be opened or a named level is invalid, an invalid root level
+// falls back to info. Use InitE to get an error instead.
 func Init(opts *Options) {
 	mu.Lock()
 	defer mu.Unlock()
-	_options = opts
+	if err := initLocked(opts, nil); err != nil {
+		panic(err)
+	}
+}
+
+// InitE initializes logger by opts like Init, but validates opts first and
+// returns an error naming the invalid field or the output path which failed to
+// open. The previous logger is left in place on failure.
+func InitE(opts *Options) error {
+	if errs := opts.Validate(); len(errs) != 0 {
+		return multierr.Combine(errs...)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	return initLocked(opts, nil)
+}
+
+// New creates a logger from opts without touching the global logger, klog or
+// the standard library logger. The returned function flushes the logger and
+// closes the files it opened, the logger must not be used after calling it.
+func New(opts *Options) (Logger, func() error, error) {
+	if errs := opts.Validate(); len(errs) != 0 {
+		return nil, nil, multierr.Combine(errs...)
+	}
+	built, err := buildLogger(opts, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	closeFunc := func() error {
+		built.Flush()
+
+		return built.sinks.closeExcept(nil)
+	}
+
+	return built.logger, closeFunc, nil
+}
+
+// initLocked builds a logger from opts and replaces the global one with it.
+// Sinks of the previous logger which are still wanted are reused, the others
+// are closed. The loggers derived from the previous global logger write to
+// the new sinks from then on. The levels set at runtime are kept unless opts
+// change them compared to prev, a nil prev resets them. The caller must hold
+// mu.
+func initLocked(opts, prev *Options) error {
+	built, err := buildLogger(opts, _sinks)
+	if err != nil {
+		return err
+	}
+	levels := built.levels
+	if current := _logger.Load(); current != nil {
+		levels = current.levels
+		levels.reload(prev, opts)
+	}
+	_core.swap(built.raw.Core())
+	_errorOutput.swap(built.errorOutput)
+	zapLogger := built.raw.WithOptions(
+		zap.ErrorOutput(_errorOutput),
+		zap.WrapCore(func(zapcore.Core) zapcore.Core {
+			return newLevelCore(_core, levels)
+		}),
+	)
+	wrapperLogger := &logger{
+		zapLogger: zapLogger,
+		levels:    levels,
+		infoLogger: infoLogger{
+			log:   zapLogger,
+			level: zap.InfoLevel,
+		},
+	}
+
+	prevSinks := _sinks
+	_logger.Store(wrapperLogger)
+	_options, _sinks = opts, built.sinks
+	_ = prevSinks.closeExcept(built.sinks)
+	klog.InitLogger(wrapperLogger.zapLogger)
+	zap.RedirectStdLog(wrapperLogger.zapLogger)
+
+	return nil
+}
+
+// builtLogger is a logger built from options, together with the parts the
+// global logger swaps in.
+type builtLogger struct {
+	*logger
+	// raw writes to the sinks without filtering the levels.
+	raw         *zap.Logger
+	errorOutput zapcore.WriteSyncer
+	sinks       sinkSet
+}
+
+// buildLogger builds a logger from opts, reusing the sinks of prev which are
+// opened with the same options.
+func buildLogger(opts *Options, prev sinkSet) (*builtLogger, error) {
+	namedLevels, errs := parseNamedLevels(opts.Levels)
+	if len(errs) != 0 {
+		return nil, multierr.Combine(errs...)
+	}
 	zapCfg := zapConfigFromOpts(opts)
 	encoder := buildEncoder(zapCfg)
 	rotOpts := buildRotationOpts(opts)
-	baseLevel := zapCfg.Level.Level()
-	teeOpts := []teeOption{normalLogOpts(baseLevel, opts, rotOpts)}
+	sinks := sinkSet{}
+	normalOpts, err := normalLogOpts(opts, rotOpts, sinks, prev)
+	if err != nil {
+		_ = sinks.closeExcept(prev)
+
+		return nil, err
+	}
+	normalOpts.w = asyncSyncer(normalOpts.w, opts, sinks)
+	teeOpts := []teeOption{normalOpts}
 	// build err log syncer
-	errSyncer, err := buildWriteSyncer(opts.ErrorOutputPaths, rotOpts)
+	errSyncer, errCoreSinks, err := buildWriteSyncer(opts.ErrorOutputPaths, rotOpts, opts, sinks, prev)
 	if err != nil {
-		panic(err)
+		_ = sinks.closeExcept(prev)
+
+		return nil, fmt.Errorf("error-output-paths: %w", err)
 	}
 	teeOpts = append(teeOpts, teeOption{
-		w:       errSyncer,
-		enabler: levelFunc(maxLevel(baseLevel, zapcore.WarnLevel), zapcore.FatalLevel),
+		w:         asyncSyncer(errSyncer, opts, sinks),
+		coreSinks: errCoreSinks,
+		enabler:   levelFunc(zapcore.WarnLevel, zapcore.FatalLevel),
 	})
 	// build zap options
+	if errSyncer == nil {
+		errSyncer = zapcore.Lock(os.Stderr)
+	}
 	zapOptions := buildZapOptions(zapCfg, errSyncer)
 	zapOptions = append(zapOptions, zap.AddStacktrace(zapcore.PanicLevel), zap.AddCallerSkip(1))
 
-	wrapperLogger, zapLogger := newTee(teeOpts, encoder, zapOptions...)
-	_logger = wrapperLogger
-	klog.InitLogger(zapLogger)
-	zap.RedirectStdLog(zapLogger)
+	wrapperLogger, raw := newTee(teeOpts, encoder, zapCfg.Level, zapOptions...)
+	for name, lvl := range namedLevels {
+		wrapperLogger.levels.setLevel(name, lvl)
+	}
+
+	return &builtLogger{logger: wrapperLogger, raw: raw, errorOutput: errSyncer, sinks: sinks}, nil
 }
 
 // StdErrLogger returns logger of standard library which writes to supplied zap
 // logger at error level.
 func StdErrLogger() *log.Logger {
-	if _logger == nil {
+	if _logger.Load() == nil {
 		return nil
 	}
-	if l, err := zap.NewStdLogAt(_logger.zapLogger, zapcore.ErrorLevel); err == nil {
+	if l, err := zap.NewStdLogAt(_logger.Load().zapLogger, zapcore.ErrorLevel); err == nil {
 		return l
 	}
 
@@ -182,10 +354,10 @@ func StdErrLogger() *log.Logger {
 // StdInfoLogger returns logger of standard library which writes to supplied zap
 // logger at info level.
 func StdInfoLogger() *log.Logger {
-	if _logger == nil {
+	if _logger.Load() == nil {
 		return nil
 	}
-	if l, err := zap.NewStdLogAt(_logger.zapLogger, zapcore.InfoLevel); err == nil {
+	if l, err := zap.NewStdLogAt(_logger.Load().zapLogger, zapcore.InfoLevel); err == nil {
 		return l
 	}
 
@@ -193,23 +365,32 @@ func StdInfoLogger() *log.Logger {
 }
 
 // V return a leveled InfoLogger.
-func V(level Level) InfoLogger { return _logger.V(level) }
+func V(level Level) InfoLogger { return _logger.Load().V(level) }
 
 // WithValues creates a child logger and adds Zap fields to it.
-func WithValues(keysAndValues ...interface{}) Logger { return _logger.WithValues(keysAndValues...) }
+func WithValues(keysAndValues ...interface{}) Logger {
+	return _logger.Load().WithValues(keysAndValues...)
+}
 
 // WithName adds a new path segment to the logger's name. Segments are joined by
 // periods. By default, Loggers are unnamed.
-func WithName(s string) Logger { return _logger.WithName(s) }
+func WithName(s string) Logger { return _logger.Load().WithName(s) }
 
 // Flush calls the underlying Core's Sync method, flushing any buffered
 // log entries. Applications should take care to call Sync before exiting.
-func Flush() { _logger.Flush() }
+func Flush() { _logger.Load().Flush() }
 
 // NewLogger creates a new logr.Logger using the given Zap Logger to log.
+// The level of the returned logger starts at the lowest level enabled by l.
 func NewLogger(l *zap.Logger) Logger {
+	levels := newLevels(zap.NewAtomicLevelAt(lowestEnabledLevel(l.Core())))
+	l = l.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return newLevelCore(core, levels)
+	}))
+
 	return &logger{
 		zapLogger: l,
+		levels:    levels,
 		infoLogger: infoLogger{
 			log:   l,
 			level: zap.InfoLevel,
@@ -219,7 +400,7 @@ func NewLogger(l *zap.Logger) Logger {
 
 // ZapLogger used for other log wrapper such as klog.
 func ZapLogger() *zap.Logger {
-	return _logger.zapLogger
+	return _logger.Load().zapLogger
 }
 
 // CheckIntLevel used for other log wrapper such as klog which return if logging a
@@ -231,101 +412,105 @@ func CheckIntLevel(level int32) bool {
 	} else {
 		lvl = zapcore.DebugLevel
 	}
-	checkEntry := _logger.zapLogger.Check(lvl, "")
+	checkEntry := _logger.Load().zapLogger.Check(lvl, "")
 
 	return checkEntry != nil
 }
 
 // Debug method output debug level log.
 func Debug(msg string, fields ...Field) {
-	_logger.zapLogger.Debug(msg, fields...)
+	_logger.Load().zapLogger.Debug(msg, fields...)
 }
 
 // Debugf method output debug level log.
 func Debugf(format string, v ...interface{}) {
-	_logger.zapLogger.Sugar().Debugf(format, v...)
+	_logger.Load().zapLogger.Sugar().Debugf(format, v...)
 }
 
 // Debugw method output debug level log.
 func Debugw(msg string, keysAndValues ...interface{}) {
-	_logger.zapLogger.Sugar().Debugw(msg, keysAndValues...)
+	_logger.Load().zapLogger.Sugar().Debugw(msg, keysAndValues...)
 }
 
 // Info method output info level log.
 func Info(msg string, fields ...Field) {
-	_logger.zapLogger.Info(msg, fields...)
+	_logger.Load().zapLogger.Info(msg, fields...)
 }
 
 // Infof method output info level log.
 func Infof(format string, v ...interface{}) {
-	_logger.zapLogger.Sugar().Infof(format, v...)
+	_logger.Load().zapLogger.Sugar().Infof(format, v...)
 }
 
 // Infow method output info level log.
 func Infow(msg string, keysAndValues ...interface{}) {
-	_logger.zapLogger.Sugar().Infow(msg, keysAndValues...)
+	_logger.Load().zapLogger.Sugar().Infow(msg, keysAndValues...)
 }
 
 // Warn method output warning level log.
 func Warn(msg string, fields ...Field) {
-	_logger.zapLogger.Warn(msg, fields...)
+	_logger.Load().zapLogger.Warn(msg, fields...)
 }
 
 // Warnf method output warning level log.
 func Warnf(format string, v ...interface{}) {
-	_logger.zapLogger.Sugar().Warnf(format, v...)
+	_logger.Load().zapLogger.Sugar().Warnf(format, v...)
 }
 
 // Warnw method output warning level log.
 func Warnw(msg string, keysAndValues ...interface{}) {
-	_logger.zapLogger.Sugar().Warnw(msg, keysAndValues...)
+	_logger.Load().zapLogger.Sugar().Warnw(msg, keysAndValues...)
 }
 
 // Error method output error level log.
 func Error(msg string, fields ...Field) {
-	_logger.zapLogger.Error(msg, fields...)
+	_logger.Load().zapLogger.Error(msg, fields...)
 }
 
 // Errorf method output error level log.
 func Errorf(format string, v ...interface{}) {
-	_logger.zapLogger.Sugar().Errorf(format, v...)
+	_logger.Load().zapLogger.Sugar().Errorf(format, v...)
 }
 
 // Errorw method output error level log.
 func Errorw(msg string, keysAndValues ...interface{}) {
-	_logger.zapLogger.Sugar().Errorw(msg, keysAndValues...)
+	_logger.Load().zapLogger.Sugar().Errorw(msg, keysAndValues...)
 }
 
 // Panic method output panic level log and shutdown application.
 func Panic(msg string, fields ...Field) {
-	_logger.zapLogger.Panic(msg, fields...)
+	_logger.Load().zapLogger.Panic(msg, fields...)
 }
 
 // Panicf method output panic level log and shutdown application.
 func Panicf(format string, v ...interface{}) {
-	_logger.zapLogger.Sugar().Panicf(format, v...)
+	_logger.Load().zapLogger.Sugar().Panicf(format, v...)
 }
 
 // Panicw method output panic level log.
 func Panicw(msg string, keysAndValues ...interface{}) {
-	_logger.zapLogger.Sugar().Panicw(msg, keysAndValues...)
+	_logger.Load().zapLogger.Sugar().Panicw(msg, keysAndValues...)
 }
 
 // Fatal method output fatal level log.
 func Fatal(msg string, fields ...Field) {
-	_logger.zapLogger.Fatal(msg, fields...)
+	_logger.Load().zapLogger.Fatal(msg, fields...)
 }
 
 // Fatalf method output fatal level log.
 func Fatalf(format string, v ...interface{}) {
-	_logger.zapLogger.Sugar().Fatalf(format, v...)
+	_logger.Load().zapLogger.Sugar().Fatalf(format, v...)
 }
 
 // Fatalw method output Fatalw level log.
 func Fatalw(msg string, keysAndValues ...interface{}) {
-	_logger.zapLogger.Sugar().Fatalw(msg, keysAndValues...)
+	_logger.Load().zapLogger.Sugar().Fatalw(msg, keysAndValues...)
 }
 
+// GetOptions returns the options the global logger was initialized with.
 func GetOptions() *Options {
+	mu.Lock()
+	defer mu.Unlock()
+
 	return _options
 }
@@ -19,6 +19,8 @@
 package log_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/spf13/pflag"
@@ -60,3 +62,141 @@ func Test_Option(t *testing.T) {
 
 	assert.Equal(t, "debug", opt.Level)
 }
+
+func Test_SetLevel(t *testing.T) {
+	defer log.Init(log.NewOptions())
+
+	path := filepath.Join(t.TempDir(), "test.log")
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{path}
+	log.Init(opts)
+
+	log.Debug("before")
+	assert.Equal(t, log.InfoLevel, log.GetLevel())
+
+	log.SetLevel(log.DebugLevel)
+	log.WithName("child").Debug("after")
+	assert.Equal(t, log.DebugLevel, log.GetLevel())
+	assert.True(t, log.CheckIntLevel(5))
+	log.Flush()
+
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "before")
+	assert.Contains(t, string(data), "after")
+}
+
+func Test_NamedLevels(t *testing.T) {
+	defer log.Init(log.NewOptions())
+
+	fs := pflag.NewFlagSet("test", pflag.ExitOnError)
+	opts := log.NewOptions()
+	opts.AddFlags(fs)
+	err := fs.Parse([]string{"--log.levels=storage=warn,scheduler.queue=debug"})
+	assert.Nil(t, err)
+	assert.Empty(t, opts.Validate())
+	log.Init(opts)
+
+	assert.Equal(t, log.InfoLevel, log.GetLevel())
+	assert.Equal(t, log.WarnLevel, log.WithName("storage").GetLevel())
+	assert.Equal(t, log.WarnLevel, log.WithName("storage").WithName("disk").GetLevel())
+	assert.Equal(t, log.InfoLevel, log.WithName("storages").GetLevel())
+	assert.Equal(t, log.InfoLevel, log.WithName("scheduler").GetLevel())
+	assert.Equal(t, log.DebugLevel, log.WithName("scheduler").WithName("queue").WithName("heap").GetLevel())
+
+	assert.False(t, log.WithName("storage").V(log.InfoLevel).Enabled())
+	assert.True(t, log.WithName("scheduler.queue").V(log.DebugLevel).Enabled())
+	assert.False(t, log.WithName("scheduler").V(log.DebugLevel).Enabled())
+
+	log.WithName("storage").WithName("disk").SetLevel(log.ErrorLevel)
+	assert.Equal(t, log.ErrorLevel, log.WithName("storage.disk").GetLevel())
+	assert.Equal(t, log.WarnLevel, log.WithName("storage").GetLevel())
+}
+
+func Test_NamedLevels_Invalid(t *testing.T) {
+	defer log.Init(log.NewOptions())
+
+	opts := log.NewOptions()
+	opts.Levels = map[string]string{"storage": "loud"}
+	assert.NotNil(t, log.InitE(opts))
+	_, _, err := log.New(opts)
+	assert.NotNil(t, err)
+	assert.Panics(t, func() { log.Init(opts) })
+}
+
+func Test_InitE(t *testing.T) {
+	defer log.Init(log.NewOptions())
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.log")
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{path}
+	assert.Nil(t, log.InitE(opts))
+
+	invalid := log.NewOptions()
+	invalid.Level = "loud"
+	assert.NotNil(t, log.InitE(invalid))
+
+	// a file can not be used as a directory.
+	badPath := filepath.Join(path, "err.log")
+	invalid = log.NewOptions()
+	invalid.ErrorOutputPaths = []string{"stderr", badPath}
+	err := log.InitE(invalid)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "error-output-paths")
+	assert.Contains(t, err.Error(), badPath)
+
+	assert.Equal(t, opts, log.GetOptions())
+	log.Info("still here")
+	log.Flush()
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "still here")
+}
+
+func Test_New(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	opts := log.NewOptions()
+	opts.Format = "json"
+	opts.OutputPaths = []string{path}
+	opts.ErrorOutputPaths = []string{path}
+
+	global := log.GetOptions()
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, global, log.GetOptions())
+
+	logger.WithName("isolated").Info("hello")
+	logger.Error("failed")
+	assert.Nil(t, closeFunc())
+
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"logger":"isolated","msg":"hello"`)
+	assert.Contains(t, string(data), `"msg":"failed"`)
+
+	opts.Level = "loud"
+	_, _, err = log.New(opts)
+	assert.NotNil(t, err)
+}
+
+func Test_New_Async(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	opts := log.NewOptions()
+	opts.Async = true
+	opts.AsyncQueueSize = 16
+	opts.OutputPaths = []string{path}
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	for i := 0; i < 100; i++ {
+		logger.Infof("entry %d", i)
+	}
+	logger.Flush()
+
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "entry 0")
+	assert.Contains(t, string(data), "entry 99")
+}
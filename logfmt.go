@@ -0,0 +1,439 @@
+package log
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var _logfmtPool = buffer.NewPool()
+
+// logfmtEncoder is a zapcore.Encoder writing entries as logfmt key=value
+// pairs. Nested objects and namespaces are flattened into dotted keys, and
+// the elements of arrays get their index as key, e.g. tags.0=a tags.1=b.
+type logfmtEncoder struct {
+	*zapcore.EncoderConfig
+	buf *buffer.Buffer
+	// prefix is the dotted key of the namespaces and objects being encoded.
+	prefix []string
+	// values counts the values appended by the encoders of the config.
+	values int
+}
+
+func newLogfmtEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return &logfmtEncoder{EncoderConfig: &cfg, buf: _logfmtPool.Get()}
+}
+
+func (enc *logfmtEncoder) Clone() zapcore.Encoder {
+	clone := &logfmtEncoder{
+		EncoderConfig: enc.EncoderConfig,
+		buf:           _logfmtPool.Get(),
+		prefix:        append([]string(nil), enc.prefix...),
+	}
+	_, _ = clone.buf.Write(enc.buf.Bytes())
+
+	return clone
+}
+
+func (enc *logfmtEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := &logfmtEncoder{EncoderConfig: enc.EncoderConfig, buf: _logfmtPool.Get()}
+	if final.TimeKey != "" {
+		final.AddTime(final.TimeKey, ent.Time)
+	}
+	if final.LevelKey != "" {
+		final.addEncoded(final.LevelKey, func() {
+			if final.EncodeLevel != nil {
+				final.EncodeLevel(ent.Level, final)
+			}
+		}, func() { final.appendString(ent.Level.String()) })
+	}
+	if ent.LoggerName != "" && final.NameKey != "" {
+		final.addEncoded(final.NameKey, func() {
+			if final.EncodeName != nil {
+				final.EncodeName(ent.LoggerName, final)
+			}
+		}, func() { final.appendString(ent.LoggerName) })
+	}
+	if ent.Caller.Defined {
+		if final.CallerKey != "" {
+			final.addEncoded(final.CallerKey, func() {
+				if final.EncodeCaller != nil {
+					final.EncodeCaller(ent.Caller, final)
+				}
+			}, func() { final.appendString(ent.Caller.String()) })
+		}
+		if final.FunctionKey != "" {
+			final.AddString(final.FunctionKey, ent.Caller.Function)
+		}
+	}
+	if final.MessageKey != "" {
+		final.AddString(final.MessageKey, ent.Message)
+	}
+	if enc.buf.Len() != 0 {
+		final.separate()
+		_, _ = final.buf.Write(enc.buf.Bytes())
+	}
+	final.prefix = append([]string(nil), enc.prefix...)
+	for _, f := range fields {
+		f.AddTo(final)
+	}
+	final.prefix = nil
+	if ent.Stack != "" && final.StacktraceKey != "" {
+		final.AddString(final.StacktraceKey, ent.Stack)
+	}
+	lineEnding := final.LineEnding
+	if lineEnding == "" {
+		lineEnding = zapcore.DefaultLineEnding
+	}
+	final.buf.AppendString(lineEnding)
+
+	return final.buf, nil
+}
+
+// separate writes the space before a pair which is not the first one.
+func (enc *logfmtEncoder) separate() {
+	if enc.buf.Len() != 0 {
+		enc.buf.AppendByte(' ')
+	}
+}
+
+// addKey writes the key of a pair, prefixed by the keys of the enclosing
+// namespaces and objects.
+func (enc *logfmtEncoder) addKey(key string) {
+	enc.separate()
+	for _, p := range enc.prefix {
+		appendLogfmtKey(enc.buf, p)
+		enc.buf.AppendByte('.')
+	}
+	appendLogfmtKey(enc.buf, key)
+	enc.buf.AppendByte('=')
+	enc.values = 0
+}
+
+// addEncoded writes the pair of key with the value appended by encode, or by
+// fallback when encode appends nothing.
+func (enc *logfmtEncoder) addEncoded(key string, encode, fallback func()) {
+	enc.addKey(key)
+	encode()
+	if enc.values == 0 {
+		fallback()
+	}
+}
+
+// appendValue writes the separator of the values appended by the encoders of
+// the config, which may append more than one.
+func (enc *logfmtEncoder) appendValue() {
+	if enc.values != 0 {
+		enc.buf.AppendByte(',')
+	}
+	enc.values++
+}
+
+func (enc *logfmtEncoder) appendString(s string) {
+	enc.appendValue()
+	appendLogfmtString(enc.buf, s)
+}
+
+func (enc *logfmtEncoder) appendFloat(f float64, bitSize int) {
+	enc.appendValue()
+	switch {
+	case math.IsNaN(f):
+		enc.buf.AppendString("NaN")
+	case math.IsInf(f, 1):
+		enc.buf.AppendString("+Inf")
+	case math.IsInf(f, -1):
+		enc.buf.AppendString("-Inf")
+	default:
+		enc.buf.AppendFloat(f, bitSize)
+	}
+}
+
+func (enc *logfmtEncoder) appendReflected(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err //nolint: wrapcheck // keep the error of the marshaler.
+	}
+	enc.appendString(string(data))
+
+	return nil
+}
+
+func (enc *logfmtEncoder) appendTime(t time.Time) {
+	if enc.EncodeTime != nil {
+		enc.EncodeTime(t, enc)
+	}
+	if enc.values == 0 {
+		enc.AppendInt64(t.UnixNano())
+	}
+}
+
+func (enc *logfmtEncoder) appendDuration(d time.Duration) {
+	if enc.EncodeDuration != nil {
+		enc.EncodeDuration(d, enc)
+	}
+	if enc.values == 0 {
+		enc.AppendInt64(int64(d))
+	}
+}
+
+// addObject writes the fields of obj with key added to the prefix, or key={}
+// when it has no fields. The namespaces opened by obj end with it.
+func (enc *logfmtEncoder) addObject(key string, obj zapcore.ObjectMarshaler) error {
+	n, depth := enc.buf.Len(), len(enc.prefix)
+	enc.prefix = append(enc.prefix, key)
+	err := obj.MarshalLogObject(enc)
+	enc.prefix = enc.prefix[:depth]
+	if enc.buf.Len() == n {
+		enc.addKey(key)
+		enc.buf.AppendString("{}")
+	}
+
+	return err //nolint: wrapcheck // keep the error of the marshaler.
+}
+
+// addArray writes the elements of arr with their index added to key, or
+// key=[] when it has no elements.
+func (enc *logfmtEncoder) addArray(key string, arr zapcore.ArrayMarshaler) error {
+	elements := &logfmtArrayEncoder{enc: enc, key: key}
+	err := arr.MarshalLogArray(elements)
+	if elements.n == 0 {
+		enc.addKey(key)
+		enc.buf.AppendString("[]")
+	}
+
+	return err //nolint: wrapcheck // keep the error of the marshaler.
+}
+
+func (enc *logfmtEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	return enc.addArray(key, arr)
+}
+
+func (enc *logfmtEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	return enc.addObject(key, obj)
+}
+
+func (enc *logfmtEncoder) AddBinary(key string, val []byte) {
+	enc.AddString(key, base64.StdEncoding.EncodeToString(val))
+}
+
+func (enc *logfmtEncoder) AddByteString(key string, val []byte) {
+	enc.AddString(key, string(val))
+}
+
+func (enc *logfmtEncoder) AddBool(key string, val bool) {
+	enc.addKey(key)
+	enc.AppendBool(val)
+}
+
+func (enc *logfmtEncoder) AddComplex128(key string, val complex128) {
+	enc.addKey(key)
+	enc.AppendComplex128(val)
+}
+
+func (enc *logfmtEncoder) AddComplex64(key string, val complex64) {
+	enc.addKey(key)
+	enc.AppendComplex64(val)
+}
+
+func (enc *logfmtEncoder) AddDuration(key string, val time.Duration) {
+	enc.addKey(key)
+	enc.appendDuration(val)
+}
+
+func (enc *logfmtEncoder) AddFloat64(key string, val float64) {
+	enc.addKey(key)
+	enc.AppendFloat64(val)
+}
+
+func (enc *logfmtEncoder) AddFloat32(key string, val float32) {
+	enc.addKey(key)
+	enc.AppendFloat32(val)
+}
+
+func (enc *logfmtEncoder) AddInt(key string, val int)     { enc.AddInt64(key, int64(val)) }
+func (enc *logfmtEncoder) AddInt32(key string, val int32) { enc.AddInt64(key, int64(val)) }
+func (enc *logfmtEncoder) AddInt16(key string, val int16) { enc.AddInt64(key, int64(val)) }
+func (enc *logfmtEncoder) AddInt8(key string, val int8)   { enc.AddInt64(key, int64(val)) }
+
+func (enc *logfmtEncoder) AddInt64(key string, val int64) {
+	enc.addKey(key)
+	enc.AppendInt64(val)
+}
+
+func (enc *logfmtEncoder) AddString(key, val string) {
+	enc.addKey(key)
+	enc.appendString(val)
+}
+
+func (enc *logfmtEncoder) AddTime(key string, val time.Time) {
+	enc.addKey(key)
+	enc.appendTime(val)
+}
+
+func (enc *logfmtEncoder) AddUint(key string, val uint)       { enc.AddUint64(key, uint64(val)) }
+func (enc *logfmtEncoder) AddUint32(key string, val uint32)   { enc.AddUint64(key, uint64(val)) }
+func (enc *logfmtEncoder) AddUint16(key string, val uint16)   { enc.AddUint64(key, uint64(val)) }
+func (enc *logfmtEncoder) AddUint8(key string, val uint8)     { enc.AddUint64(key, uint64(val)) }
+func (enc *logfmtEncoder) AddUintptr(key string, val uintptr) { enc.AddUint64(key, uint64(val)) }
+
+func (enc *logfmtEncoder) AddUint64(key string, val uint64) {
+	enc.addKey(key)
+	enc.AppendUint64(val)
+}
+
+func (enc *logfmtEncoder) AddReflected(key string, val interface{}) error {
+	enc.addKey(key)
+
+	return enc.appendReflected(val)
+}
+
+func (enc *logfmtEncoder) OpenNamespace(key string) {
+	enc.prefix = append(enc.prefix, key)
+}
+
+// The Append methods write the values appended by the encoders of the config.
+
+func (enc *logfmtEncoder) AppendBool(val bool) {
+	enc.appendValue()
+	enc.buf.AppendBool(val)
+}
+
+func (enc *logfmtEncoder) AppendByteString(val []byte) { enc.appendString(string(val)) }
+
+func (enc *logfmtEncoder) AppendComplex128(val complex128) {
+	enc.appendValue()
+	enc.buf.AppendString(strconv.FormatComplex(val, 'f', -1, 128))
+}
+
+func (enc *logfmtEncoder) AppendComplex64(val complex64) {
+	enc.appendValue()
+	enc.buf.AppendString(strconv.FormatComplex(complex128(val), 'f', -1, 64))
+}
+
+func (enc *logfmtEncoder) AppendFloat64(val float64) { enc.appendFloat(val, 64) }
+func (enc *logfmtEncoder) AppendFloat32(val float32) { enc.appendFloat(float64(val), 32) }
+func (enc *logfmtEncoder) AppendInt(val int)         { enc.AppendInt64(int64(val)) }
+func (enc *logfmtEncoder) AppendInt32(val int32)     { enc.AppendInt64(int64(val)) }
+func (enc *logfmtEncoder) AppendInt16(val int16)     { enc.AppendInt64(int64(val)) }
+func (enc *logfmtEncoder) AppendInt8(val int8)       { enc.AppendInt64(int64(val)) }
+
+func (enc *logfmtEncoder) AppendInt64(val int64) {
+	enc.appendValue()
+	enc.buf.AppendInt(val)
+}
+
+func (enc *logfmtEncoder) AppendString(val string) { enc.appendString(val) }
+func (enc *logfmtEncoder) AppendUint(val uint)     { enc.AppendUint64(uint64(val)) }
+func (enc *logfmtEncoder) AppendUint32(val uint32) { enc.AppendUint64(uint64(val)) }
+func (enc *logfmtEncoder) AppendUint16(val uint16) { enc.AppendUint64(uint64(val)) }
+func (enc *logfmtEncoder) AppendUint8(val uint8)   { enc.AppendUint64(uint64(val)) }
+
+func (enc *logfmtEncoder) AppendUintptr(val uintptr) { enc.AppendUint64(uint64(val)) }
+
+func (enc *logfmtEncoder) AppendUint64(val uint64) {
+	enc.appendValue()
+	enc.buf.AppendUint(val)
+}
+
+// logfmtArrayEncoder writes the elements of an array as pairs keyed by their
+// index.
+type logfmtArrayEncoder struct {
+	enc *logfmtEncoder
+	key string
+	n   int
+}
+
+// next writes the key of the next element and returns it.
+func (a *logfmtArrayEncoder) next() string {
+	key := a.key + "." + strconv.Itoa(a.n)
+	a.n++
+	a.enc.addKey(key)
+
+	return key
+}
+
+func (a *logfmtArrayEncoder) AppendArray(arr zapcore.ArrayMarshaler) error {
+	key := a.key + "." + strconv.Itoa(a.n)
+	a.n++
+
+	return a.enc.addArray(key, arr)
+}
+
+func (a *logfmtArrayEncoder) AppendObject(obj zapcore.ObjectMarshaler) error {
+	key := a.key + "." + strconv.Itoa(a.n)
+	a.n++
+
+	return a.enc.addObject(key, obj)
+}
+
+func (a *logfmtArrayEncoder) AppendReflected(val interface{}) error {
+	a.next()
+
+	return a.enc.appendReflected(val)
+}
+
+func (a *logfmtArrayEncoder) AppendBool(val bool)             { a.next(); a.enc.AppendBool(val) }
+func (a *logfmtArrayEncoder) AppendByteString(val []byte)     { a.next(); a.enc.AppendByteString(val) }
+func (a *logfmtArrayEncoder) AppendComplex128(val complex128) { a.next(); a.enc.AppendComplex128(val) }
+func (a *logfmtArrayEncoder) AppendComplex64(val complex64)   { a.next(); a.enc.AppendComplex64(val) }
+func (a *logfmtArrayEncoder) AppendFloat64(val float64)       { a.next(); a.enc.AppendFloat64(val) }
+func (a *logfmtArrayEncoder) AppendFloat32(val float32)       { a.next(); a.enc.AppendFloat32(val) }
+func (a *logfmtArrayEncoder) AppendInt(val int)               { a.next(); a.enc.AppendInt(val) }
+func (a *logfmtArrayEncoder) AppendInt64(val int64)           { a.next(); a.enc.AppendInt64(val) }
+func (a *logfmtArrayEncoder) AppendInt32(val int32)           { a.next(); a.enc.AppendInt32(val) }
+func (a *logfmtArrayEncoder) AppendInt16(val int16)           { a.next(); a.enc.AppendInt16(val) }
+func (a *logfmtArrayEncoder) AppendInt8(val int8)             { a.next(); a.enc.AppendInt8(val) }
+func (a *logfmtArrayEncoder) AppendString(val string)         { a.next(); a.enc.AppendString(val) }
+func (a *logfmtArrayEncoder) AppendUint(val uint)             { a.next(); a.enc.AppendUint(val) }
+func (a *logfmtArrayEncoder) AppendUint64(val uint64)         { a.next(); a.enc.AppendUint64(val) }
+func (a *logfmtArrayEncoder) AppendUint32(val uint32)         { a.next(); a.enc.AppendUint32(val) }
+func (a *logfmtArrayEncoder) AppendUint16(val uint16)         { a.next(); a.enc.AppendUint16(val) }
+func (a *logfmtArrayEncoder) AppendUint8(val uint8)           { a.next(); a.enc.AppendUint8(val) }
+func (a *logfmtArrayEncoder) AppendUintptr(val uintptr)       { a.next(); a.enc.AppendUintptr(val) }
+func (a *logfmtArrayEncoder) AppendDuration(val time.Duration) {
+	a.next()
+	a.enc.appendDuration(val)
+}
+
+func (a *logfmtArrayEncoder) AppendTime(val time.Time) {
+	a.next()
+	a.enc.appendTime(val)
+}
+
+// appendLogfmtKey writes key with the spaces, quotes, equal signs and control
+// characters replaced by underscores.
+func appendLogfmtKey(buf *buffer.Buffer, key string) {
+	if key == "" {
+		buf.AppendByte('_')
+
+		return
+	}
+	buf.AppendString(strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || !unicode.IsPrint(r) {
+			return '_'
+		}
+
+		return r
+	}, key))
+}
+
+// appendLogfmtString writes s, quoted and escaped when it is empty or holds
+// spaces, quotes, equal signs or control characters.
+func appendLogfmtString(buf *buffer.Buffer, s string) {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || !unicode.IsPrint(r)
+	}) < 0 {
+		buf.AppendString(s)
+
+		return
+	}
+	_, _ = buf.Write(strconv.AppendQuote(nil, s))
+}
@@ -0,0 +1,119 @@
+package log
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type logfmtUser struct {
+	Name  string
+	Roles []string
+}
+
+func (u logfmtUser) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", u.Name)
+
+	return enc.AddArray("roles", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		for _, role := range u.Roles {
+			arr.AppendString(role)
+		}
+
+		return nil
+	}))
+}
+
+func Test_logfmtEncoder(t *testing.T) {
+	opts := NewOptions()
+	opts.Format = logfmtFormat
+	opts.EnableColor = true
+	enc := newLogfmtEncoder(encoderConfigFromOpts(opts))
+	enc = enc.Clone()
+	zap.String("request id", "r-1").AddTo(enc)
+	zap.Namespace("http").AddTo(enc)
+
+	ent := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.Date(2024, 5, 6, 7, 8, 9, 10e6, time.Local),
+		LoggerName: "api",
+		Message:    "slow request",
+		Caller:     zapcore.NewEntryCaller(0, "/src/app/handler.go", 42, true),
+		Stack:      "main.main\n\t/src/main.go:10",
+	}
+	buf, err := enc.EncodeEntry(ent, []zapcore.Field{
+		zap.String("path", "/a b"),
+		zap.String("query", `q="x"`),
+		zap.String("empty", ""),
+		zap.Duration("latency", 1500*time.Microsecond),
+		zap.Int("status", 200),
+		zap.Bool("cached", false),
+		zap.Float64("ratio", 0.25),
+		zap.Error(errors.New("timeout\nafter 1s")),
+		zap.Object("user", logfmtUser{Name: "alice", Roles: []string{"admin", "dev"}}),
+		zap.Strings("tags", nil),
+		zap.Any("meta", map[string]int{"retries": 2}),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `time="2024-05-06 07:08:09.010" level=WARN logger=api caller=app/handler.go:42 `+
+		`msg="slow request" request_id=r-1 http.path="/a b" http.query="q=\"x\"" http.empty="" `+
+		`http.latency=1.5 http.status=200 http.cached=false http.ratio=0.25 http.error="timeout\nafter 1s" `+
+		`http.user.name=alice http.user.roles.0=admin http.user.roles.1=dev http.tags=[] `+
+		`http.meta="{\"retries\":2}" stacktrace="main.main\n\t/src/main.go:10"`+"\n", buf.String())
+	buf.Free()
+
+	// the fields of the entry do not leak into the encoder.
+	buf, err = enc.EncodeEntry(zapcore.Entry{Time: ent.Time, Message: "ok"}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, `time="2024-05-06 07:08:09.010" level=INFO msg=ok request_id=r-1`+"\n", buf.String())
+	buf.Free()
+}
+
+func Test_logfmtEncoder_Arrays(t *testing.T) {
+	enc := newLogfmtEncoder(zapcore.EncoderConfig{MessageKey: "msg"})
+	buf, err := enc.EncodeEntry(zapcore.Entry{Message: "batch"}, []zapcore.Field{
+		zap.Objects("users", []logfmtUser{{Name: "bob"}}),
+		zap.Durations("waits", []time.Duration{time.Second}),
+		zap.Array("matrix", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+			return arr.AppendArray(zapcore.ArrayMarshalerFunc(func(inner zapcore.ArrayEncoder) error {
+				inner.AppendInt(1)
+				inner.AppendTime(time.Unix(0, 5))
+
+				return nil
+			}))
+		})),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "msg=batch users.0.name=bob users.0.roles=[] waits.0=1000000000 "+
+		"matrix.0.0=1 matrix.0.1=5\n", buf.String())
+	buf.Free()
+}
+
+func Test_logfmtEncoder_ObjectNamespace(t *testing.T) {
+	enc := newLogfmtEncoder(zapcore.EncoderConfig{MessageKey: "msg"})
+	buf, err := enc.EncodeEntry(zapcore.Entry{Message: "nested"}, []zapcore.Field{
+		zap.Object("obj", zapcore.ObjectMarshalerFunc(func(obj zapcore.ObjectEncoder) error {
+			obj.AddInt("a", 1)
+			obj.OpenNamespace("ns")
+			obj.AddInt("b", 2)
+
+			return nil
+		})),
+		zap.String("after", "x"),
+	})
+	assert.Nil(t, err)
+	// the namespace opened by the object ends with it.
+	assert.Equal(t, "msg=nested obj.a=1 obj.ns.b=2 after=x\n", buf.String())
+	buf.Free()
+}
+
+func Test_Options_LogfmtFormat(t *testing.T) {
+	opts := NewOptions()
+	opts.Format = logfmtFormat
+	assert.Empty(t, opts.Validate())
+	_, ok := buildEncoder(zapConfigFromOpts(opts)).(*logfmtEncoder)
+	assert.True(t, ok)
+}
@@ -57,6 +57,8 @@ type logger struct {
 	// NB: this looks very similar to zap.SugaredLogger, but
 	// deals with our desire to have multiple verbosity levels.
 	zapLogger *zap.Logger
+	levels    *levels
+	name      string
 	infoLogger
 }
 
@@ -83,16 +85,41 @@ func (l *logger) Flush() {
 	_ = l.zapLogger.Sync()
 }
 
+func (l *logger) SetLevel(level Level) {
+	l.levels.setLevel(l.name, level)
+}
+
+func (l *logger) GetLevel() Level {
+	return l.levels.levelOf(l.name)
+}
+
 func (l *logger) WithName(name string) Logger {
-	newLogger := l.zapLogger.Named(name)
+	fullName := joinName(l.name, name)
+	newLogger := l.zapLogger.Named(name).WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return namedCore(core, fullName)
+	}))
 
-	return NewLogger(newLogger)
+	return l.clone(newLogger, fullName)
 }
 
 func (l *logger) WithValues(keysAndValues ...interface{}) Logger {
 	newLogger := l.zapLogger.With(handleFields(l.zapLogger, keysAndValues)...)
 
-	return NewLogger(newLogger)
+	return l.clone(newLogger, l.name)
+}
+
+// clone returns a logger named name which writes to zapLogger and shares the
+// levels of l.
+func (l *logger) clone(zapLogger *zap.Logger, name string) *logger {
+	return &logger{
+		zapLogger: zapLogger,
+		levels:    l.levels,
+		name:      name,
+		infoLogger: infoLogger{
+			log:   zapLogger,
+			level: zap.InfoLevel,
+		},
+	}
 }
 
 func (l *logger) Debug(msg string, fields ...Field) {
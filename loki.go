@@ -0,0 +1,194 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const lokiPushPath = "/loki/api/v1/push"
+
+// nolint: gochecknoinits // register the loki output path schemes.
+func init() {
+	scheme := sinkScheme{open: openLokiSink, config: lokiConfig, buffered: true}
+	_sinkSchemes["loki+http"] = scheme
+	_sinkSchemes["loki+https"] = scheme
+}
+
+// LokiOptions configures the loki+http://host:3100 and loki+https:// output
+// paths, which push the entries to Grafana Loki. The streams are labeled with
+// the name of the logger as app, the level and the fields listed in Labels,
+// the other fields stay in the lines. Credentials of the url are sent with
+// basic auth.
+type LokiOptions struct {
+	Labels []string `json:"labels"    mapstructure:"labels"`
+	// TenantID is sent as the X-Scope-OrgID header when set.
+	TenantID string `json:"tenant-id"   mapstructure:"tenant-id"`
+	// MaxRetries bounds how many times a failed push is sent again, with an
+	// exponential backoff, before its batch is dropped.
+	MaxRetries int          `json:"max-retries" mapstructure:"max-retries"`
+	Batch      BatchOptions `json:"batch"       mapstructure:"batch"`
+}
+
+func (o LokiOptions) validate() []error {
+	var errs []error
+	if o.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("negative loki max retries: %d", o.MaxRetries))
+	}
+
+	return append(errs, o.Batch.validate("loki")...)
+}
+
+type lokiSinkConfig struct {
+	loki   LokiOptions
+	name   string
+	stream streamSinkConfig
+}
+
+func lokiConfig(opts *Options) interface{} {
+	return lokiSinkConfig{loki: opts.Loki, name: opts.Name, stream: streamConfig(opts).(streamSinkConfig)}
+}
+
+// lokiEntry is an entry buffered by a loki output path.
+type lokiEntry struct {
+	Labels map[string]string `json:"labels"`
+	Time   string            `json:"time"`
+	Line   string            `json:"line"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func openLokiSink(u *url.URL, opts *Options) (*sink, error) {
+	target := httpTarget(u, lokiPushPath)
+	client, err := newHTTPClient(target, opts)
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[string]string, len(opts.Loki.Labels))
+	for _, key := range opts.Loki.Labels {
+		labels[key] = lokiLabelName(key)
+	}
+	name, tenant := opts.Name, opts.Loki.TenantID
+
+	encode := func(enc zapcore.Encoder, ent zapcore.Entry, fields []zapcore.Field) ([]byte, error) {
+		entry := lokiEntry{
+			Labels: map[string]string{"level": ent.Level.String()},
+			Time:   strconv.FormatInt(ent.Time.UnixNano(), 10),
+		}
+		if name != "" {
+			entry.Labels["app"] = name
+		}
+		var lineFields []zapcore.Field
+		for _, f := range fields {
+			label, ok := labels[f.Key]
+			if !ok {
+				lineFields = append(lineFields, f)
+
+				continue
+			}
+			values := zapcore.NewMapObjectEncoder()
+			f.AddTo(values)
+			entry.Labels[label] = fieldString(values.Fields[f.Key])
+		}
+		buf, err := enc.EncodeEntry(ent, lineFields)
+		if err != nil {
+			return nil, err //nolint: wrapcheck // keep the error of the encoder.
+		}
+		entry.Line = strings.TrimRight(buf.String(), "\n")
+		buf.Free()
+
+		return json.Marshal(entry) //nolint: wrapcheck // a lokiEntry always marshals.
+	}
+
+	// the batches failing with retryable errors are pushed again up to
+	// MaxRetries times by the buffered writer.
+	send := func(batch [][]byte) error {
+		var streams []*lokiStream
+		byLabels := map[string]*lokiStream{}
+		for _, record := range batch {
+			var entry lokiEntry
+			if err := json.Unmarshal(record, &entry); err != nil {
+				continue
+			}
+			key := lokiStreamKey(entry.Labels)
+			stream, ok := byLabels[key]
+			if !ok {
+				stream = &lokiStream{Stream: entry.Labels}
+				byLabels[key] = stream
+				streams = append(streams, stream)
+			}
+			stream.Values = append(stream.Values, [2]string{entry.Time, entry.Line})
+		}
+		body, err := json.Marshal(map[string][]*lokiStream{"streams": streams})
+		if err != nil {
+			return &permanentError{err: fmt.Errorf("marshal loki push: %w", err)}
+		}
+
+		req, err := http.NewRequest(http.MethodPost, target.String(), bytes.NewReader(gzipBody(body)))
+		if err != nil {
+			return &permanentError{err: fmt.Errorf("build loki push: %w", err)}
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+		if tenant != "" {
+			req.Header.Set("X-Scope-OrgID", tenant)
+		}
+		if u.User != nil {
+			password, _ := u.User.Password()
+			req.SetBasicAuth(u.User.Username(), password)
+		}
+		_, err = doRequest(client, req)
+		var permanent *permanentError
+		if err == nil || errors.As(err, &permanent) {
+			return err
+		}
+
+		return &retryError{err: err, retry: batch}
+	}
+	policy := opts.Loki.Batch.policy()
+	policy.retries = opts.Loki.MaxRetries
+
+	return newBatchSink(u, opts, policy, encode, send)
+}
+
+// lokiLabelName converts key to a label name: letters, digits and
+// underscores, not starting with a digit.
+func lokiLabelName(key string) string {
+	name := []byte(key)
+	for i, c := range name {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 || (name[0] >= '0' && name[0] <= '9') {
+		return "_" + string(name)
+	}
+
+	return string(name)
+}
+
+// lokiStreamKey returns a key identifying the stream of labels.
+func lokiStreamKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s=%q,", k, labels[k])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,151 @@
+package log_test
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/huanghe314/log"
+)
+
+type lokiPush struct {
+	Streams []struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	} `json:"streams"`
+}
+
+// lokiServer records the pushes it receives, answering the first ones with
+// the given status codes.
+type lokiServer struct {
+	mu       sync.Mutex
+	statuses []int
+	pushes   []lokiPush
+	tenants  []string
+}
+
+func (s *lokiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.statuses) != 0 {
+		w.WriteHeader(s.statuses[0])
+		s.statuses = s.statuses[1:]
+
+		return
+	}
+	if r.URL.Path != "/loki/api/v1/push" || r.Header.Get("Content-Encoding") != "gzip" {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+	gz, err := gzip.NewReader(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+	var push lokiPush
+	if err := json.NewDecoder(gz).Decode(&push); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+	s.pushes = append(s.pushes, push)
+	s.tenants = append(s.tenants, r.Header.Get("X-Scope-OrgID"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func Test_Loki(t *testing.T) {
+	server := &lokiServer{statuses: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	opts := log.NewOptions()
+	opts.Name = "app"
+	opts.Format = "json"
+	opts.OutputPaths = []string{"loki+" + srv.URL}
+	opts.ErrorOutputPaths = opts.OutputPaths
+	opts.Loki.Labels = []string{"region"}
+	opts.Loki.TenantID = "team-a"
+	opts.Loki.Batch.WaitInMS = 60000
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	logger.WithValues("region", "eu").Infow("first", "user", "alice")
+	logger.WithValues("region", "eu").Info("second")
+	logger.Error("failed")
+	// the batch waits for more entries until it is flushed, and is retried
+	// after 429 and 503.
+	logger.Flush()
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Len(t, server.pushes, 1)
+	assert.Equal(t, []string{"team-a"}, server.tenants)
+	streams := server.pushes[0].Streams
+	assert.Len(t, streams, 2)
+	assert.Equal(t, map[string]string{"app": "app", "level": "info", "region": "eu"}, streams[0].Stream)
+	assert.Len(t, streams[0].Values, 2)
+	assert.Contains(t, streams[0].Values[0][1], `"msg":"first","user":"alice"`)
+	assert.False(t, strings.Contains(streams[0].Values[0][1], "region"))
+	assert.Equal(t, map[string]string{"app": "app", "level": "error"}, streams[1].Stream)
+	assert.Contains(t, streams[1].Values[0][1], `"msg":"failed"`)
+}
+
+func Test_Loki_Permanent(t *testing.T) {
+	server := &lokiServer{statuses: []int{http.StatusBadRequest}}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{"loki+" + srv.URL}
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	dropped := log.DroppedEntries()
+	logger.Info("rejected")
+	logger.Flush()
+	assert.Equal(t, uint64(1), log.DroppedEntries()-dropped)
+
+	logger.Info("accepted")
+	logger.Flush()
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Len(t, server.pushes, 1)
+}
+
+func Test_Loki_Retries(t *testing.T) {
+	server := &lokiServer{statuses: []int{http.StatusServiceUnavailable, http.StatusBadGateway}}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{"loki+" + srv.URL}
+	opts.Loki.MaxRetries = 1
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	// the batch is dropped once its retry failed too.
+	dropped := log.DroppedEntries()
+	logger.Info("unavailable")
+	logger.Flush()
+	assert.Equal(t, uint64(1), log.DroppedEntries()-dropped)
+
+	logger.Info("accepted")
+	logger.Flush()
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Len(t, server.pushes, 1)
+
+	opts.Loki.MaxRetries = -1
+	assert.Len(t, opts.Validate(), 1)
+}
@@ -0,0 +1,421 @@
+package log
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// msgpackEventTime is the extension type of the EventTime of the forward
+// protocol of fluentd.
+const msgpackEventTime = 0
+
+// maxMsgpackLength bounds the strings, binaries and containers decoded.
+const maxMsgpackLength = 64 << 20
+
+// msgpackExt is a decoded MessagePack extension.
+type msgpackExt struct {
+	Type int8
+	Data []byte
+}
+
+// appendMsgpack appends v encoded as MessagePack to b. It supports the values
+// of a zapcore.MapObjectEncoder, durations are encoded in milliseconds like
+// the other encoders do and unknown values are encoded through JSON.
+func appendMsgpack(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0)
+	case bool:
+		if v {
+			return append(b, 0xc3)
+		}
+
+		return append(b, 0xc2)
+	case string:
+		return appendMsgpackString(b, v)
+	case []byte:
+		return appendMsgpackBin(b, v)
+	case int:
+		return appendMsgpackInt(b, int64(v))
+	case int8:
+		return appendMsgpackInt(b, int64(v))
+	case int16:
+		return appendMsgpackInt(b, int64(v))
+	case int32:
+		return appendMsgpackInt(b, int64(v))
+	case int64:
+		return appendMsgpackInt(b, v)
+	case uint:
+		return appendMsgpackUint(b, uint64(v))
+	case uint8:
+		return appendMsgpackUint(b, uint64(v))
+	case uint16:
+		return appendMsgpackUint(b, uint64(v))
+	case uint32:
+		return appendMsgpackUint(b, uint64(v))
+	case uint64:
+		return appendMsgpackUint(b, v)
+	case uintptr:
+		return appendMsgpackUint(b, uint64(v))
+	case float32:
+		return appendMsgpackFloat(b, float64(v))
+	case float64:
+		return appendMsgpackFloat(b, v)
+	case time.Time:
+		return appendMsgpackString(b, v.Format(time.RFC3339Nano))
+	case time.Duration:
+		return appendMsgpackFloat(b, float64(v)/float64(time.Millisecond))
+	case complex64, complex128:
+		return appendMsgpackString(b, fmt.Sprint(v))
+	case []interface{}:
+		b = appendMsgpackArrayHeader(b, len(v))
+		for _, e := range v {
+			b = appendMsgpack(b, e)
+		}
+
+		return b
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = appendMsgpackMapHeader(b, len(v))
+		for _, k := range keys {
+			b = appendMsgpackString(b, k)
+			b = appendMsgpack(b, v[k])
+		}
+
+		return b
+	case error:
+		return appendMsgpackString(b, v.Error())
+	case fmt.Stringer:
+		return appendMsgpackString(b, v.String())
+	}
+
+	// reflected values are encoded as the JSON encoder of zap would do.
+	data, err := json.Marshal(v)
+	if err != nil {
+		return appendMsgpackString(b, fmt.Sprint(v))
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return appendMsgpackString(b, string(data))
+	}
+
+	return appendMsgpack(b, decoded)
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, 0xda)
+		b = appendBigEndian16(b, uint16(n))
+	default:
+		b = append(b, 0xdb)
+		b = appendBigEndian32(b, uint32(n))
+	}
+
+	return append(b, s...)
+}
+
+func appendMsgpackBin(b []byte, data []byte) []byte {
+	switch n := len(data); {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, 0xc5)
+		b = appendBigEndian16(b, uint16(n))
+	default:
+		b = append(b, 0xc6)
+		b = appendBigEndian32(b, uint32(n))
+	}
+
+	return append(b, data...)
+}
+
+func appendMsgpackInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0:
+		return appendMsgpackUint(b, uint64(i))
+	case i >= -32:
+		return append(b, byte(i))
+	case i >= math.MinInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return appendBigEndian16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return appendBigEndian32(append(b, 0xd2), uint32(i))
+	default:
+		return appendBigEndian64(append(b, 0xd3), uint64(i))
+	}
+}
+
+func appendMsgpackUint(b []byte, u uint64) []byte {
+	switch {
+	case u < 128:
+		return append(b, byte(u))
+	case u <= math.MaxUint8:
+		return append(b, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		return appendBigEndian16(append(b, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		return appendBigEndian32(append(b, 0xce), uint32(u))
+	default:
+		return appendBigEndian64(append(b, 0xcf), u)
+	}
+}
+
+func appendMsgpackFloat(b []byte, f float64) []byte {
+	return appendBigEndian64(append(b, 0xcb), math.Float64bits(f))
+}
+
+func appendMsgpackArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return appendBigEndian16(append(b, 0xdc), uint16(n))
+	default:
+		return appendBigEndian32(append(b, 0xdd), uint32(n))
+	}
+}
+
+func appendMsgpackMapHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return appendBigEndian16(append(b, 0xde), uint16(n))
+	default:
+		return appendBigEndian32(append(b, 0xdf), uint32(n))
+	}
+}
+
+func appendBigEndian16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendBigEndian32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendBigEndian64(b []byte, v uint64) []byte {
+	return appendBigEndian32(appendBigEndian32(b, uint32(v>>32)), uint32(v))
+}
+
+// appendMsgpackEventTime appends t as an EventTime of the forward protocol.
+func appendMsgpackEventTime(b []byte, t time.Time) []byte {
+	b = append(b, 0xd7, msgpackEventTime)
+	b = appendBigEndian32(b, uint32(t.Unix()))
+
+	return appendBigEndian32(b, uint32(t.Nanosecond()))
+}
+
+var errMsgpackFormat = errors.New("invalid msgpack format")
+
+// decodeMsgpack decodes a MessagePack value from r. Maps are decoded to
+// map[interface{}]interface{} unless every key is a string.
+func decodeMsgpack(r io.Reader) (interface{}, error) {
+	var tag [1]byte
+	if _, err := io.ReadFull(r, tag[:]); err != nil {
+		return nil, err //nolint: wrapcheck // keep io.EOF.
+	}
+	c := tag[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return readMsgpackString(r, int(c&0x1f))
+	case c&0xf0 == 0x90:
+		return readMsgpackArray(r, int(c&0x0f))
+	case c&0xf0 == 0x80:
+		return readMsgpackMap(r, int(c&0x0f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := readMsgpackLength(r, 1<<(c-0xc4))
+		if err != nil {
+			return nil, err
+		}
+
+		return readMsgpackBytes(r, n)
+	case 0xc7, 0xc8, 0xc9:
+		n, err := readMsgpackLength(r, 1<<(c-0xc7))
+		if err != nil {
+			return nil, err
+		}
+
+		return readMsgpackExt(r, n)
+	case 0xca:
+		u, err := readMsgpackLength(r, 4)
+
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := readMsgpackUint64(r)
+
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce:
+		u, err := readMsgpackLength(r, 1<<(c-0xcc))
+
+		return int64(u), err
+	case 0xcf:
+		u, err := readMsgpackUint64(r)
+		if u > math.MaxInt64 {
+			return u, err
+		}
+
+		return int64(u), err
+	case 0xd0:
+		u, err := readMsgpackLength(r, 1)
+
+		return int64(int8(u)), err
+	case 0xd1:
+		u, err := readMsgpackLength(r, 2)
+
+		return int64(int16(u)), err
+	case 0xd2:
+		u, err := readMsgpackLength(r, 4)
+
+		return int64(int32(u)), err
+	case 0xd3:
+		u, err := readMsgpackUint64(r)
+
+		return int64(u), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return readMsgpackExt(r, 1<<(c-0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := readMsgpackLength(r, 1<<(c-0xd9))
+		if err != nil {
+			return nil, err
+		}
+
+		return readMsgpackString(r, n)
+	case 0xdc, 0xdd:
+		n, err := readMsgpackLength(r, 2<<(c-0xdc))
+		if err != nil {
+			return nil, err
+		}
+
+		return readMsgpackArray(r, n)
+	case 0xde, 0xdf:
+		n, err := readMsgpackLength(r, 2<<(c-0xde))
+		if err != nil {
+			return nil, err
+		}
+
+		return readMsgpackMap(r, n)
+	}
+
+	return nil, errMsgpackFormat
+}
+
+// readMsgpackLength reads a big endian unsigned integer of size bytes.
+func readMsgpackLength(r io.Reader, size int) (int, error) {
+	var buf [4]byte
+	if _, err := io.ReadFull(r, buf[4-size:]); err != nil {
+		return 0, err //nolint: wrapcheck // keep the error of the reader.
+	}
+
+	return int(binary.BigEndian.Uint32(buf[:])), nil
+}
+
+func readMsgpackUint64(r io.Reader) (uint64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err //nolint: wrapcheck // keep the error of the reader.
+	}
+
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+func readMsgpackBytes(r io.Reader, n int) ([]byte, error) {
+	if n > maxMsgpackLength {
+		return nil, errMsgpackFormat
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err //nolint: wrapcheck // keep the error of the reader.
+	}
+
+	return data, nil
+}
+
+func readMsgpackString(r io.Reader, n int) (string, error) {
+	data, err := readMsgpackBytes(r, n)
+
+	return string(data), err
+}
+
+func readMsgpackExt(r io.Reader, n int) (msgpackExt, error) {
+	data, err := readMsgpackBytes(r, n+1)
+	if err != nil {
+		return msgpackExt{}, err
+	}
+
+	return msgpackExt{Type: int8(data[0]), Data: data[1:]}, nil
+}
+
+func readMsgpackArray(r io.Reader, n int) ([]interface{}, error) {
+	if n > maxMsgpackLength {
+		return nil, errMsgpackFormat
+	}
+	values := make([]interface{}, n)
+	for i := range values {
+		v, err := decodeMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+
+	return values, nil
+}
+
+func readMsgpackMap(r io.Reader, n int) (interface{}, error) {
+	if n > maxMsgpackLength {
+		return nil, errMsgpackFormat
+	}
+	values := make(map[interface{}]interface{}, n)
+	strings := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := decodeMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		v, err := decodeMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := k.(string); ok && strings != nil {
+			strings[s] = v
+		} else {
+			strings = nil
+		}
+		values[k] = v
+	}
+	if strings != nil {
+		return strings, nil
+	}
+
+	return values, nil
+}
@@ -21,6 +21,8 @@ package log
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"sort"
 	"strings"
 
 	"github.com/spf13/pflag"
@@ -39,9 +41,74 @@ const (
 	flagDisableStacktrace = "log.disable-stacktrace"
 	flagMaxSizeInMB       = "log.max-size-mb"
 	flagMaxAgeInDays      = "log.max-age-days"
+	flagLevels            = "log.levels"
+	flagMaxBackups        = "log.max-backups"
+	flagCompress          = "log.compress"
+	flagLocalTime         = "log.local-time"
+	flagRotationInterval  = "log.rotation-interval"
+	flagExternalRotation  = "log.external-rotation"
+	flagAsync             = "log.async"
+	flagAsyncQueueSize    = "log.async-queue-size"
+	flagAsyncOverflow     = "log.async-overflow"
+	flagAsyncFlushInMS    = "log.async-flush-interval-ms"
+	flagSyslogFacility    = "log.syslog-facility"
+	flagSyslogAppName     = "log.syslog-app-name"
+	flagSyslogFormat      = "log.syslog-format"
+	flagStreamBufferInKB  = "log.stream-buffer-size-kb"
+	flagStreamFlushInMS   = "log.stream-flush-timeout-ms"
+	flagTLSCAFile         = "log.tls-ca-file"
+	flagTLSCertFile       = "log.tls-cert-file"
+	flagTLSKeyFile        = "log.tls-key-file"
+	flagTLSServerName     = "log.tls-server-name"
+	flagTLSInsecure       = "log.tls-insecure-skip-verify"
+	flagLokiLabels        = "log.loki-labels"
+	flagLokiTenantID      = "log.loki-tenant-id"
+	flagLokiBatchSizeInKB = "log.loki-batch-size-kb"
+	flagLokiBatchWaitInMS = "log.loki-batch-wait-ms"
+	flagLokiMaxRetries    = "log.loki-max-retries"
+	flagESIndex           = "log.es-index"
+	flagESAPIKey          = "log.es-api-key"
+	flagESAPIKeyFile      = "log.es-api-key-file"
+	flagESBatchEntries    = "log.es-batch-max-entries"
+	flagESBatchSizeInKB   = "log.es-batch-size-kb"
+	flagESBatchWaitInMS   = "log.es-batch-wait-ms"
+	flagFluentMode        = "log.fluent-mode"
+	flagFluentRequireAck  = "log.fluent-require-ack"
+	flagFluentBatchInKB   = "log.fluent-batch-size-kb"
+	flagFluentBatchInMS   = "log.fluent-batch-wait-ms"
+	flagHTTPFormat        = "log.http-format"
+	flagHTTPTemplate      = "log.http-template"
+	flagHTTPHeaders       = "log.http-headers"
+	flagHTTPTokenFile     = "log.http-bearer-token-file"
+	flagHTTPMaxRetries    = "log.http-max-retries"
+	flagHTTPDeadLetter    = "log.http-dead-letter-file"
+	flagHTTPBatchEntries  = "log.http-batch-max-entries"
+	flagHTTPBatchSizeInKB = "log.http-batch-size-kb"
+	flagHTTPBatchWaitInMS = "log.http-batch-wait-ms"
+	flagGELFCompress      = "log.gelf-compress"
+	flagGELFChunkSize     = "log.gelf-chunk-size"
+	flagOTLPEncoding      = "log.otlp-encoding"
+	flagOTLPHeaders       = "log.otlp-headers"
+	flagOTLPBatchEntries  = "log.otlp-batch-max-entries"
+	flagOTLPBatchSizeInKB = "log.otlp-batch-size-kb"
+	flagOTLPBatchWaitInMS = "log.otlp-batch-wait-ms"
+	flagTimeKey           = "log.time-key"
+	flagLevelKey          = "log.level-key"
+	flagNameKey           = "log.name-key"
+	flagCallerKey         = "log.caller-key"
+	flagMessageKey        = "log.message-key"
+	flagStacktraceKey     = "log.stacktrace-key"
+	flagTimeFormat        = "log.time-format"
+	flagTimeZone          = "log.time-zone"
+	flagDurationFormat    = "log.duration-format"
+	flagLevelFormat       = "log.level-format"
+
+	// redactedSecret replaces the secrets of printed options.
+	redactedSecret = "xxxxx"
 
 	consoleFormat = "console"
 	jsonFormat    = "json"
+	logfmtFormat  = "logfmt"
 )
 
 // Options contains configuration items related to log.
@@ -57,23 +124,128 @@ type Options struct {
 	Name              string   `json:"name"               mapstructure:"name"`
 	MaxSizeInMB       int      `json:"max-size-in-mb"     mapstructure:"max-size-in-mb"`
 	MaxAgeInDays      int      `json:"max-age-in-days"    mapstructure:"max-age-in-days"`
+	MaxBackups        int      `json:"max-backups"        mapstructure:"max-backups"`
+	Compress          bool     `json:"compress"           mapstructure:"compress"`
+	LocalTime         bool     `json:"local-time"         mapstructure:"local-time"`
+	RotationInterval  string   `json:"rotation-interval"  mapstructure:"rotation-interval"`
+	ExternalRotation  bool     `json:"external-rotation"  mapstructure:"external-rotation"`
+	Async             bool     `json:"async"              mapstructure:"async"`
+	AsyncQueueSize    int      `json:"async-queue-size"   mapstructure:"async-queue-size"`
+	AsyncOverflow     string   `json:"async-overflow"     mapstructure:"async-overflow"`
+	// AsyncFlushIntervalInMS is the interval of syncing the sinks of an async
+	// logger, 0 disables the periodic sync.
+	AsyncFlushIntervalInMS int `json:"async-flush-interval-in-ms" mapstructure:"async-flush-interval-in-ms"`
+	// Levels overrides Level for named loggers, the longest name prefix wins.
+	Levels map[string]string `json:"levels" mapstructure:"levels"`
+	// Rotations overrides the rotation options of single output paths.
+	Rotations map[string]RotationOptions `json:"rotations" mapstructure:"rotations"`
+	// Syslog configures the syslog output paths.
+	Syslog SyslogOptions `json:"syslog" mapstructure:"syslog"`
+	// Stream configures the tcp and tls output paths and the buffers of the
+	// other remote output paths.
+	Stream StreamOptions `json:"stream" mapstructure:"stream"`
+	// TLS configures the TLS connections of remote output paths.
+	TLS TLSOptions `json:"tls" mapstructure:"tls"`
+	// Spools sets spool directories keeping the entries of remote output
+	// paths on disk until they are delivered.
+	Spools map[string]SpoolOptions `json:"spools" mapstructure:"spools"`
+	// Loki configures the loki output paths.
+	Loki LokiOptions `json:"loki" mapstructure:"loki"`
+	// Elasticsearch configures the es output paths.
+	Elasticsearch ElasticsearchOptions `json:"elasticsearch" mapstructure:"elasticsearch"`
+	// Fluent configures the fluent output paths.
+	Fluent FluentOptions `json:"fluent" mapstructure:"fluent"`
+	// HTTP configures the http and https output paths.
+	HTTP HTTPOptions `json:"http" mapstructure:"http"`
+	// GELF configures the gelf output paths.
+	GELF GELFOptions `json:"gelf" mapstructure:"gelf"`
+	// OTLP configures the otlp output paths.
+	OTLP OTLPOptions `json:"otlp" mapstructure:"otlp"`
+	// Encoder configures the keys, the time, the durations and the levels
+	// written by the encoders.
+	Encoder EncoderOptions `json:"encoder" mapstructure:"encoder"`
+
+	// envErrs holds the errors met by ApplyEnv, they are reported by Validate.
+	envErrs []error
+}
+
+// RotationOptions overrides the rotation of a single output path. Nil fields
+// and an empty Interval keep the value set in Options, so that a path can set
+// MaxAgeInDays or MaxBackups back to 0.
+type RotationOptions struct {
+	MaxSizeInMB  *int   `json:"max-size-in-mb"  mapstructure:"max-size-in-mb"`
+	MaxAgeInDays *int   `json:"max-age-in-days" mapstructure:"max-age-in-days"`
+	MaxBackups   *int   `json:"max-backups"     mapstructure:"max-backups"`
+	Compress     *bool  `json:"compress"        mapstructure:"compress"`
+	LocalTime    *bool  `json:"local-time"      mapstructure:"local-time"`
+	Interval     string `json:"interval"        mapstructure:"interval"`
 }
 
 // NewOptions creates Options object with default parameters.
 func NewOptions() *Options {
 	return &Options{
-		Level:            zapcore.InfoLevel.String(),
-		Format:           consoleFormat,
-		EnableColor:      false,
-		EnableCaller:     false,
-		OutputPaths:      []string{"stdout"},
-		ErrorOutputPaths: []string{"stderr"},
+		Level:                  zapcore.InfoLevel.String(),
+		Format:                 consoleFormat,
+		EnableColor:            false,
+		EnableCaller:           false,
+		OutputPaths:            []string{"stdout"},
+		ErrorOutputPaths:       []string{"stderr"},
+		MaxSizeInMB:            _defaultRotateOpts.maxSize,
+		MaxAgeInDays:           _defaultRotateOpts.maxAge,
+		MaxBackups:             _defaultRotateOpts.maxBackups,
+		Compress:               _defaultRotateOpts.compress,
+		LocalTime:              _defaultRotateOpts.localTime,
+		AsyncQueueSize:         1024,
+		AsyncOverflow:          overflowBlock,
+		AsyncFlushIntervalInMS: 1000,
+		Syslog: SyslogOptions{
+			Facility: "user",
+			Format:   rfc5424,
+		},
+		Stream: StreamOptions{
+			BufferSizeInKB:   defaultStreamBufferInKB,
+			FlushTimeoutInMS: 5000,
+		},
+		Loki: LokiOptions{
+			MaxRetries: 5,
+			Batch:      BatchOptions{MaxSizeInKB: 1024, WaitInMS: 1000},
+		},
+		Elasticsearch: ElasticsearchOptions{
+			Batch: BatchOptions{MaxEntries: 500, MaxSizeInKB: 5120, WaitInMS: 1000},
+		},
+		Fluent: FluentOptions{
+			Mode:  fluentForward,
+			Batch: BatchOptions{MaxSizeInKB: 1024, WaitInMS: 1000},
+		},
+		HTTP: HTTPOptions{
+			Format:     httpNDJSON,
+			MaxRetries: 5,
+			Batch:      BatchOptions{MaxEntries: 500, MaxSizeInKB: 1024, WaitInMS: 1000},
+		},
+		GELF: GELFOptions{
+			ChunkSize: defaultGELFChunkSize,
+		},
+		OTLP: OTLPOptions{
+			Encoding: otlpProtobuf,
+			Batch:    BatchOptions{MaxEntries: 512, MaxSizeInKB: 1024, WaitInMS: 1000},
+		},
+		Encoder: EncoderOptions{
+			TimeKey:        "time",
+			LevelKey:       "level",
+			NameKey:        "logger",
+			CallerKey:      "caller",
+			MessageKey:     "msg",
+			StacktraceKey:  "stacktrace",
+			TimeFormat:     defaultTimeLayout,
+			DurationFormat: durationMillis,
+			LevelFormat:    levelCapital,
+		},
 	}
 }
 
 // Validate validate the options fields.
 func (o *Options) Validate() []error {
-	var errs []error
+	errs := append([]error(nil), o.envErrs...)
 
 	var zapLevel zapcore.Level
 	if err := zapLevel.UnmarshalText([]byte(o.Level)); err != nil {
@@ -81,17 +253,60 @@ func (o *Options) Validate() []error {
 	}
 
 	format := strings.ToLower(o.Format)
-	if format != consoleFormat && format != jsonFormat {
+	if format != consoleFormat && format != jsonFormat && format != logfmtFormat && format != ecsFormat && format != gelfFormat {
 		errs = append(errs, fmt.Errorf("not a valid log format: %q", o.Format))
 	}
 
+	if o.Async {
+		if o.AsyncQueueSize <= 0 {
+			errs = append(errs, fmt.Errorf("not a valid async queue size: %d", o.AsyncQueueSize))
+		}
+		switch o.AsyncOverflow {
+		case overflowBlock, overflowDropNewest, overflowDropOldest:
+		default:
+			errs = append(errs, fmt.Errorf("not a valid async overflow policy: %q", o.AsyncOverflow))
+		}
+		if o.AsyncFlushIntervalInMS < 0 {
+			errs = append(errs, fmt.Errorf("negative async flush interval: %d", o.AsyncFlushIntervalInMS))
+		}
+	}
+
+	_, levelErrs := parseNamedLevels(o.Levels)
+	errs = append(errs, levelErrs...)
+	errs = append(errs, o.Syslog.validate()...)
+	errs = append(errs, o.Stream.validate()...)
+	errs = append(errs, o.TLS.validate()...)
+	errs = append(errs, validateSpools(o)...)
+	errs = append(errs, o.Loki.validate()...)
+	errs = append(errs, o.Elasticsearch.validate()...)
+	errs = append(errs, o.Fluent.validate()...)
+	errs = append(errs, o.HTTP.validate()...)
+	errs = append(errs, o.GELF.validate()...)
+	errs = append(errs, o.OTLP.validate()...)
+	errs = append(errs, o.Encoder.validate()...)
+
+	errs = append(errs, validateRotation("", RotationOptions{
+		MaxSizeInMB:  &o.MaxSizeInMB,
+		MaxAgeInDays: &o.MaxAgeInDays,
+		MaxBackups:   &o.MaxBackups,
+		Interval:     o.RotationInterval,
+	})...)
+	paths := make([]string, 0, len(o.Rotations))
+	for path := range o.Rotations {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		errs = append(errs, validateRotation(path, o.Rotations[path])...)
+	}
+
 	return errs
 }
 
 // AddFlags adds flags for log to the specified FlagSet object.
 func (o *Options) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.Level, flagLevel, o.Level, "Minimum log output `LEVEL`.")
-	fs.StringVar(&o.Format, flagFormat, o.Format, "Log output `FORMAT`, support plain or json format.")
+	fs.StringVar(&o.Format, flagFormat, o.Format, "Log output `FORMAT`, support plain, json, logfmt, ecs or gelf format.")
 	fs.BoolVar(&o.EnableColor, flagEnableColor, o.EnableColor, "Enable output ansi colors in plain format logs.")
 	fs.BoolVar(&o.EnableCaller, flagEnableCaller, o.EnableCaller, "Enable output of caller information in the log.")
 	fs.StringSliceVar(&o.OutputPaths, flagOutputPaths, o.OutputPaths, "Output paths of log.")
@@ -108,10 +323,190 @@ func (o *Options) AddFlags(fs *pflag.FlagSet) {
 		o.DisableStacktrace, "Disable the log to record a stack trace for all messages at or above panic level.")
 	fs.IntVar(&o.MaxSizeInMB, flagMaxSizeInMB, o.MaxSizeInMB, "The max size in MB.")
 	fs.IntVar(&o.MaxAgeInDays, flagMaxAgeInDays, o.MaxAgeInDays, "The max age in Days.")
+	fs.IntVar(&o.MaxBackups, flagMaxBackups, o.MaxBackups,
+		"The max number of rotated log files to retain, 0 retains all of them.")
+	fs.BoolVar(&o.Compress, flagCompress, o.Compress, "Compress rotated log files with gzip.")
+	fs.BoolVar(&o.LocalTime, flagLocalTime, o.LocalTime,
+		"Use the local time instead of UTC in the names of rotated log files.")
+	fs.StringVar(&o.RotationInterval, flagRotationInterval, o.RotationInterval,
+		"Start a new log file every `INTERVAL`, support hourly or daily. The file name gets the "+
+			"time of the interval inserted before its extension unless it holds %Y, %m, %d or %H verbs.")
+	fs.BoolVar(&o.ExternalRotation, flagExternalRotation, o.ExternalRotation,
+		"Leave the rotation of log files to an external tool such as logrotate. Files are plain "+
+			"appenders which are reopened on SIGHUP.")
+	fs.BoolVar(&o.Async, flagAsync, o.Async, "Write logs from a background goroutine through a bounded queue.")
+	fs.IntVar(&o.AsyncQueueSize, flagAsyncQueueSize, o.AsyncQueueSize, "The max number of entries queued by async logs.")
+	fs.StringVar(&o.AsyncOverflow, flagAsyncOverflow, o.AsyncOverflow,
+		"What async logs do when the queue is full, support block, drop-newest or drop-oldest.")
+	fs.IntVar(&o.AsyncFlushIntervalInMS, flagAsyncFlushInMS, o.AsyncFlushIntervalInMS,
+		"The interval in milliseconds of syncing async logs, 0 disables it.")
+	fs.Var(newMapValue(&o.Levels), flagLevels,
+		"Minimum log output `LEVEL` of named loggers, e.g. storage=warn,scheduler.queue=debug. "+
+			"The longest logger name prefix wins.")
+	fs.StringVar(&o.Syslog.Facility, flagSyslogFacility, o.Syslog.Facility,
+		"The `FACILITY` of syslog output paths, e.g. user, daemon or local0.")
+	fs.StringVar(&o.Syslog.AppName, flagSyslogAppName, o.Syslog.AppName,
+		"The app name of syslog output paths, defaults to the name of the logger.")
+	fs.StringVar(&o.Syslog.Format, flagSyslogFormat, o.Syslog.Format,
+		"The `FORMAT` of syslog messages, support rfc5424 or rfc3164.")
+	fs.IntVar(&o.Stream.BufferSizeInKB, flagStreamBufferInKB, o.Stream.BufferSizeInKB,
+		"The max size in KB of the entries buffered by remote output paths while they are unreachable.")
+	fs.IntVar(&o.Stream.FlushTimeoutInMS, flagStreamFlushInMS, o.Stream.FlushTimeoutInMS,
+		"How long in milliseconds flushing remote output paths waits for the buffered entries, 0 does not wait.")
+	fs.StringVar(&o.TLS.CAFile, flagTLSCAFile, o.TLS.CAFile,
+		"The PEM encoded CA file verifying remote log servers, defaults to the system CAs.")
+	fs.StringVar(&o.TLS.CertFile, flagTLSCertFile, o.TLS.CertFile,
+		"The PEM encoded client certificate file presented to remote log servers.")
+	fs.StringVar(&o.TLS.KeyFile, flagTLSKeyFile, o.TLS.KeyFile, "The PEM encoded key file of the client certificate.")
+	fs.StringVar(&o.TLS.ServerName, flagTLSServerName, o.TLS.ServerName,
+		"The server name verified on remote log servers, defaults to their host.")
+	fs.BoolVar(&o.TLS.InsecureSkipVerify, flagTLSInsecure, o.TLS.InsecureSkipVerify,
+		"Skip verifying the certificates of remote log servers.")
+	fs.StringSliceVar(&o.Loki.Labels, flagLokiLabels, o.Loki.Labels,
+		"The fields turned into the labels of the streams of loki output paths.")
+	fs.StringVar(&o.Loki.TenantID, flagLokiTenantID, o.Loki.TenantID, "The tenant ID of loki output paths.")
+	fs.IntVar(&o.Loki.Batch.MaxSizeInKB, flagLokiBatchSizeInKB, o.Loki.Batch.MaxSizeInKB,
+		"The max size in KB of the batches pushed to loki output paths.")
+	fs.IntVar(&o.Loki.Batch.WaitInMS, flagLokiBatchWaitInMS, o.Loki.Batch.WaitInMS,
+		"How long in milliseconds a batch waits for more entries before it is pushed to loki output paths.")
+	fs.IntVar(&o.Loki.MaxRetries, flagLokiMaxRetries, o.Loki.MaxRetries,
+		"How many times a failed push to loki output paths is sent again before its batch is dropped.")
+	fs.StringVar(&o.Elasticsearch.Index, flagESIndex, o.Elasticsearch.Index,
+		"The index `PATTERN` of es output paths, %Y, %m, %d and %H are replaced by the time of the entries. "+
+			"Defaults to the name of the logger followed by -%Y.%m.%d.")
+	fs.StringVar(&o.Elasticsearch.APIKey, flagESAPIKey, o.Elasticsearch.APIKey,
+		"The API key of es output paths, used instead of the credentials of their url.")
+	fs.StringVar(&o.Elasticsearch.APIKeyFile, flagESAPIKeyFile, o.Elasticsearch.APIKeyFile,
+		"The file of the API key of es output paths, read again when it changes.")
+	fs.IntVar(&o.Elasticsearch.Batch.MaxEntries, flagESBatchEntries, o.Elasticsearch.Batch.MaxEntries,
+		"The max number of entries of the bulk requests of es output paths.")
+	fs.IntVar(&o.Elasticsearch.Batch.MaxSizeInKB, flagESBatchSizeInKB, o.Elasticsearch.Batch.MaxSizeInKB,
+		"The max size in KB of the bulk requests of es output paths.")
+	fs.IntVar(&o.Elasticsearch.Batch.WaitInMS, flagESBatchWaitInMS, o.Elasticsearch.Batch.WaitInMS,
+		"How long in milliseconds a bulk request waits for more entries before it is sent to es output paths.")
+	fs.StringVar(&o.Fluent.Mode, flagFluentMode, o.Fluent.Mode,
+		"The `MODE` of the forward protocol of fluent output paths, support forward or packed-forward.")
+	fs.BoolVar(&o.Fluent.RequireAck, flagFluentRequireAck, o.Fluent.RequireAck,
+		"Require fluent output paths to acknowledge the entries they receive, which are sent again otherwise.")
+	fs.IntVar(&o.Fluent.Batch.MaxSizeInKB, flagFluentBatchInKB, o.Fluent.Batch.MaxSizeInKB,
+		"The max size in KB of the batches sent to fluent output paths.")
+	fs.IntVar(&o.Fluent.Batch.WaitInMS, flagFluentBatchInMS, o.Fluent.Batch.WaitInMS,
+		"How long in milliseconds a batch waits for more entries before it is sent to fluent output paths.")
+	fs.StringVar(&o.HTTP.Format, flagHTTPFormat, o.HTTP.Format,
+		"The `FORMAT` of the bodies of http output paths, support ndjson, json-array or template.")
+	fs.StringVar(&o.HTTP.Template, flagHTTPTemplate, o.HTTP.Template,
+		"The text/template of the bodies of http output paths in template format, executed with the entries.")
+	fs.Var(newMapValue(&o.HTTP.Headers), flagHTTPHeaders,
+		"The headers of the requests of http output paths, e.g. X-Source=app.")
+	fs.StringVar(&o.HTTP.BearerTokenFile, flagHTTPTokenFile, o.HTTP.BearerTokenFile,
+		"The file of the bearer token of http output paths, read again when it changes.")
+	fs.IntVar(&o.HTTP.MaxRetries, flagHTTPMaxRetries, o.HTTP.MaxRetries,
+		"How many times a failed request of http output paths is sent again before its entries are rejected.")
+	fs.StringVar(&o.HTTP.DeadLetterFile, flagHTTPDeadLetter, o.HTTP.DeadLetterFile,
+		"The file receiving the entries rejected by http output paths, they are dropped when it is not set.")
+	fs.IntVar(&o.HTTP.Batch.MaxEntries, flagHTTPBatchEntries, o.HTTP.Batch.MaxEntries,
+		"The max number of entries of the requests of http output paths.")
+	fs.IntVar(&o.HTTP.Batch.MaxSizeInKB, flagHTTPBatchSizeInKB, o.HTTP.Batch.MaxSizeInKB,
+		"The max size in KB of the requests of http output paths.")
+	fs.IntVar(&o.HTTP.Batch.WaitInMS, flagHTTPBatchWaitInMS, o.HTTP.Batch.WaitInMS,
+		"How long in milliseconds a request waits for more entries before it is sent to http output paths.")
+	fs.BoolVar(&o.GELF.Compress, flagGELFCompress, o.GELF.Compress, "Compress the messages of gelf output paths with gzip.")
+	fs.IntVar(&o.GELF.ChunkSize, flagGELFChunkSize, o.GELF.ChunkSize,
+		"The max size in bytes of the datagrams of gelf output paths, larger messages are chunked.")
+	fs.StringVar(&o.OTLP.Encoding, flagOTLPEncoding, o.OTLP.Encoding,
+		"The `ENCODING` of the requests of otlp output paths, support protobuf or json.")
+	fs.Var(newMapValue(&o.OTLP.Headers), flagOTLPHeaders,
+		"The headers of the requests of otlp output paths, e.g. api-key=secret.")
+	fs.IntVar(&o.OTLP.Batch.MaxEntries, flagOTLPBatchEntries, o.OTLP.Batch.MaxEntries,
+		"The max number of log records of the requests of otlp output paths.")
+	fs.IntVar(&o.OTLP.Batch.MaxSizeInKB, flagOTLPBatchSizeInKB, o.OTLP.Batch.MaxSizeInKB,
+		"The max size in KB of the requests of otlp output paths.")
+	fs.IntVar(&o.OTLP.Batch.WaitInMS, flagOTLPBatchWaitInMS, o.OTLP.Batch.WaitInMS,
+		"How long in milliseconds a request waits for more log records before it is sent to otlp output paths.")
+	fs.StringVar(&o.Encoder.TimeKey, flagTimeKey, o.Encoder.TimeKey, "The key of the time of the entries.")
+	fs.StringVar(&o.Encoder.LevelKey, flagLevelKey, o.Encoder.LevelKey, "The key of the level of the entries.")
+	fs.StringVar(&o.Encoder.NameKey, flagNameKey, o.Encoder.NameKey, "The key of the logger name of the entries.")
+	fs.StringVar(&o.Encoder.CallerKey, flagCallerKey, o.Encoder.CallerKey, "The key of the caller of the entries.")
+	fs.StringVar(&o.Encoder.MessageKey, flagMessageKey, o.Encoder.MessageKey, "The key of the message of the entries.")
+	fs.StringVar(&o.Encoder.StacktraceKey, flagStacktraceKey, o.Encoder.StacktraceKey,
+		"The key of the stack trace of the entries.")
+	fs.StringVar(&o.Encoder.TimeFormat, flagTimeFormat, o.Encoder.TimeFormat,
+		"The `FORMAT` of the time of the entries, support rfc3339, rfc3339nano, epoch, epoch-millis, "+
+			"epoch-nanos or a Go time layout.")
+	fs.StringVar(&o.Encoder.TimeZone, flagTimeZone, o.Encoder.TimeZone,
+		"The time `ZONE` of the time of the entries, support UTC, Local or an IANA name such as Asia/Shanghai. "+
+			"Defaults to the local time zone.")
+	fs.StringVar(&o.Encoder.DurationFormat, flagDurationFormat, o.Encoder.DurationFormat,
+		"The `FORMAT` of the durations of the entries, support string, nanos, millis or seconds.")
+	fs.StringVar(&o.Encoder.LevelFormat, flagLevelFormat, o.Encoder.LevelFormat,
+		"The `FORMAT` of the level of the entries, support capital or lowercase.")
+}
+
+func validateRotation(path string, rotation RotationOptions) []error {
+	var errs []error
+	if path != "" {
+		path = fmt.Sprintf(" of %q", path)
+	}
+	if n := rotation.MaxSizeInMB; n != nil && *n < 0 {
+		errs = append(errs, fmt.Errorf("negative max size in MB%s: %d", path, *n))
+	}
+	if n := rotation.MaxAgeInDays; n != nil && *n < 0 {
+		errs = append(errs, fmt.Errorf("negative max age in days%s: %d", path, *n))
+	}
+	if n := rotation.MaxBackups; n != nil && *n < 0 {
+		errs = append(errs, fmt.Errorf("negative max backups%s: %d", path, *n))
+	}
+	if i := rotation.Interval; i != "" && i != hourlyInterval && i != dailyInterval {
+		errs = append(errs, fmt.Errorf("not a valid rotation interval%s: %q", path, i))
+	}
+
+	return errs
 }
 
 func (o *Options) String() string {
-	data, _ := json.Marshal(o) //nolint: errchkjson
+	data, _ := json.Marshal(o.redacted()) //nolint: errchkjson
 
 	return string(data)
 }
+
+// redacted returns a copy of o for printing, in which the API keys, the
+// values of the headers and the passwords of the output paths are masked.
+func (o *Options) redacted() *Options {
+	r := *o
+	if r.Elasticsearch.APIKey != "" {
+		r.Elasticsearch.APIKey = redactedSecret
+	}
+	r.HTTP.Headers = redactValues(o.HTTP.Headers)
+	r.OTLP.Headers = redactValues(o.OTLP.Headers)
+	r.OutputPaths = redactPaths(o.OutputPaths)
+	r.ErrorOutputPaths = redactPaths(o.ErrorOutputPaths)
+
+	return &r
+}
+
+func redactValues(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(m))
+	for k := range m {
+		redacted[k] = redactedSecret
+	}
+
+	return redacted
+}
+
+func redactPaths(paths []string) []string {
+	if paths == nil {
+		return nil
+	}
+	redacted := make([]string, len(paths))
+	for i, path := range paths {
+		redacted[i] = path
+		if u, err := url.Parse(path); err == nil && u.User != nil {
+			redacted[i] = u.Redacted()
+		}
+	}
+
+	return redacted
+}
@@ -19,9 +19,14 @@
 package log_test
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/huanghe314/log"
@@ -41,3 +46,153 @@ func Test_Options_Validate(t *testing.T) {
 	expected := `[unrecognized level: "test" not a valid log format: "test"]`
 	assert.Equal(t, expected, fmt.Sprintf("%s", errs))
 }
+
+func Test_Options_Validate_Levels(t *testing.T) {
+	opts := log.NewOptions()
+	opts.Levels = map[string]string{"storage": "loud", "": "debug"}
+
+	errs := opts.Validate()
+	expected := `[empty logger name in log.levels invalid level for logger "storage": unrecognized level: "loud"]`
+	assert.Equal(t, expected, fmt.Sprintf("%s", errs))
+}
+
+func Test_Options_ApplyEnv(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("LOG_OUTPUT_PATHS", "stdout, app.log")
+	t.Setenv("LOG_ENABLE_CALLER", "true")
+	t.Setenv("LOG_MAX_SIZE_IN_MB", "10")
+	t.Setenv("LOG_LEVELS", "storage=warn,scheduler.queue=debug")
+
+	opts := log.NewOptions()
+	opts.ApplyEnv("LOG")
+	assert.Empty(t, opts.Validate())
+	assert.Equal(t, "warn", opts.Level)
+	assert.Equal(t, []string{"stdout", "app.log"}, opts.OutputPaths)
+	assert.True(t, opts.EnableCaller)
+	assert.Equal(t, 10, opts.MaxSizeInMB)
+	assert.Equal(t, map[string]string{"storage": "warn", "scheduler.queue": "debug"}, opts.Levels)
+}
+
+func Test_Options_ApplyEnv_Errors(t *testing.T) {
+	t.Setenv("APP_LOG_ENABLE_COLOR", "yes please")
+	t.Setenv("APP_LOG_MAX_AGE_IN_DAYS", "week")
+
+	opts := log.NewOptions()
+	opts.ApplyEnv("APP_LOG_")
+	errs := opts.Validate()
+	assert.Len(t, errs, 2)
+	assert.Contains(t, errs[0].Error(), "APP_LOG_ENABLE_COLOR")
+	assert.Contains(t, errs[1].Error(), "APP_LOG_MAX_AGE_IN_DAYS")
+	assert.False(t, opts.EnableColor)
+
+	// the errors of a previous ApplyEnv are not reported again.
+	t.Setenv("APP_LOG_ENABLE_COLOR", "true")
+	t.Setenv("APP_LOG_MAX_AGE_IN_DAYS", "7")
+	opts.ApplyEnv("APP_LOG_")
+	assert.Empty(t, opts.Validate())
+	assert.True(t, opts.EnableColor)
+}
+
+// Test_Options_Precedence documents the precedence of the option sources:
+// defaults < file < env < flags.
+func Test_Options_Precedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte("level: debug\nformat: json\nname: file\n"), 0o600))
+	t.Setenv("LOG_FORMAT", "console")
+	t.Setenv("LOG_NAME", "env")
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	log.NewOptions().AddFlags(fs)
+	assert.Nil(t, fs.Parse([]string{"--log.name=flag"}))
+
+	opts, err := log.LoadOptionsFromFile(path)
+	assert.Nil(t, err)
+	opts.ApplyEnv("LOG")
+	assert.Nil(t, opts.ApplyFlags(fs))
+	assert.Empty(t, opts.Validate())
+
+	assert.Equal(t, []string{"stdout"}, opts.OutputPaths) // default
+	assert.Equal(t, "debug", opts.Level)                  // file
+	assert.Equal(t, "console", opts.Format)               // env
+	assert.Equal(t, "flag", opts.Name)                    // flags
+}
+
+func Test_Options_Rotation(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	opts := log.NewOptions()
+	opts.AddFlags(fs)
+	assert.Nil(t, fs.Parse([]string{"--log.max-backups=5", "--log.compress", "--log.local-time"}))
+	assert.Equal(t, 5, opts.MaxBackups)
+	assert.True(t, opts.Compress)
+	assert.True(t, opts.LocalTime)
+
+	t.Setenv("LOG_ROTATIONS", `{"err.log": {"max-backups": 30, "compress": false}}`)
+	opts.ApplyEnv("LOG")
+	assert.Empty(t, opts.Validate())
+	assert.Equal(t, 30, *opts.Rotations["err.log"].MaxBackups)
+	assert.Nil(t, opts.Rotations["err.log"].MaxAgeInDays)
+
+	age := -1
+	opts.Rotations["err.log"] = log.RotationOptions{MaxAgeInDays: &age}
+	errs := opts.Validate()
+	assert.Equal(t, `[negative max age in days of "err.log": -1]`, fmt.Sprintf("%s", errs))
+}
+
+func Test_Options_Encoder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	opts := log.NewOptions()
+	opts.AddFlags(fs)
+	assert.Nil(t, fs.Parse([]string{
+		"--log.format=json", "--log.output-paths=" + path,
+		"--log.time-key=ts", "--log.message-key=message", "--log.level-key=severity",
+		"--log.time-format=rfc3339", "--log.time-zone=UTC",
+		"--log.duration-format=string", "--log.level-format=lowercase",
+	}))
+	assert.Empty(t, opts.Validate())
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	logger.Infow("done", "took", 1500*time.Millisecond)
+	assert.Nil(t, closeFunc())
+
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	var entry map[string]interface{}
+	assert.Nil(t, json.Unmarshal(data, &entry))
+	assert.Equal(t, "done", entry["message"])
+	assert.Equal(t, "info", entry["severity"])
+	assert.Equal(t, "1.5s", entry["took"])
+	ts, err := time.Parse(time.RFC3339, entry["ts"].(string))
+	assert.Nil(t, err)
+	assert.WithinDuration(t, time.Now(), ts, time.Minute)
+	assert.Equal(t, time.UTC, ts.Location())
+
+	// empty keys leave their values out, they are not duplicates.
+	opts.Encoder.TimeKey, opts.Encoder.StacktraceKey = "", ""
+	assert.Empty(t, opts.Validate())
+	assert.Nil(t, os.Remove(path))
+	logger, closeFunc, err = log.New(opts)
+	assert.Nil(t, err)
+	logger.Info("untimed")
+	assert.Nil(t, closeFunc())
+	data, err = os.ReadFile(path)
+	assert.Nil(t, err)
+	entry = nil
+	assert.Nil(t, json.Unmarshal(data, &entry))
+	assert.Equal(t, map[string]interface{}{"message": "untimed", "severity": "info"}, entry)
+
+	opts.Encoder = log.EncoderOptions{
+		LevelKey:       "msg",
+		MessageKey:     "msg",
+		TimeFormat:     "today",
+		TimeZone:       "Mars/Olympus",
+		DurationFormat: "weeks",
+		LevelFormat:    "upper",
+	}
+	errs := opts.Validate()
+	assert.Equal(t, `duplicate encoder key: "msg"`, errs[0].Error())
+	assert.Equal(t, `not a valid time format: "today"`, errs[1].Error())
+	assert.Contains(t, errs[2].Error(), "not a valid time zone")
+	assert.Equal(t, `not a valid duration format: "weeks"`, errs[3].Error())
+	assert.Equal(t, `not a valid level format: "upper"`, errs[4].Error())
+}
@@ -0,0 +1,465 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	otlpLogsPath = "/v1/logs"
+
+	otlpJSON     = "json"
+	otlpProtobuf = "protobuf"
+)
+
+// nolint: gochecknoinits // register the otlp output path schemes.
+func init() {
+	scheme := sinkScheme{open: openOTLPSink, config: otlpConfig, buffered: true}
+	_sinkSchemes["otlp+http"] = scheme
+	_sinkSchemes["otlp+https"] = scheme
+}
+
+// OTLPOptions configures the otlp+http://host:4318 and otlp+https:// output
+// paths, which export the entries as OpenTelemetry log records to /v1/logs.
+// The name of the logger of an entry is its instrumentation scope, and the
+// ids set by WithSpanContext are its trace and span ids.
+type OTLPOptions struct {
+	// Encoding is the encoding of the requests, protobuf or json.
+	Encoding string            `json:"encoding" mapstructure:"encoding"`
+	Headers  map[string]string `json:"headers"  mapstructure:"headers"`
+	Batch    BatchOptions      `json:"batch"    mapstructure:"batch"`
+}
+
+func (o OTLPOptions) validate() []error {
+	var errs []error
+	if o.Encoding != "" && o.Encoding != otlpJSON && o.Encoding != otlpProtobuf {
+		errs = append(errs, fmt.Errorf("not a valid otlp encoding: %q", o.Encoding))
+	}
+
+	return append(errs, o.Batch.validate("otlp")...)
+}
+
+type otlpSinkConfig struct {
+	otlp   OTLPOptions
+	name   string
+	stream streamSinkConfig
+}
+
+func otlpConfig(opts *Options) interface{} {
+	return otlpSinkConfig{otlp: opts.OTLP, name: opts.Name, stream: streamConfig(opts).(streamSinkConfig)}
+}
+
+// The OTLP types are marshaled to the JSON encoding of OTLP, and buffered as
+// such.
+
+type otlpAnyValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *int64          `json:"intValue,string,omitempty"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+	KvlistValue *otlpKvlist     `json:"kvlistValue,omitempty"`
+	BytesValue  []byte          `json:"bytesValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+type otlpKvlist struct {
+	Values []otlpKeyValue `json:"values"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         uint64         `json:"timeUnixNano,string"`
+	ObservedTimeUnixNano uint64         `json:"observedTimeUnixNano,string"`
+	SeverityNumber       int            `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+	TraceID              string         `json:"traceId,omitempty"`
+	SpanID               string         `json:"spanId,omitempty"`
+}
+
+type otlpScopeLogs struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpResourceLogs struct {
+	Resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeLogs []*otlpScopeLogs `json:"scopeLogs"`
+}
+
+// otlpEntry is an entry buffered by an otlp output path.
+type otlpEntry struct {
+	Scope  string        `json:"scope"`
+	Record otlpLogRecord `json:"record"`
+}
+
+func openOTLPSink(u *url.URL, opts *Options) (*sink, error) {
+	target := httpTarget(u, otlpLogsPath)
+	client, err := newHTTPClient(target, opts)
+	if err != nil {
+		return nil, err
+	}
+	cfg := opts.OTLP
+	var resource otlpResourceLogs
+	resource.Resource.Attributes = otlpResource(opts.Name)
+
+	encode := func(_ zapcore.Encoder, ent zapcore.Entry, fields []zapcore.Field) ([]byte, error) {
+		entry := otlpEntry{Scope: ent.LoggerName, Record: otlpLogRecord{
+			TimeUnixNano:         uint64(ent.Time.UnixNano()),
+			ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+			SeverityNumber:       otlpSeverity(ent.Level),
+			SeverityText:         ent.Level.CapitalString(),
+			Body:                 otlpValue(ent.Message),
+		}}
+		values := zapcore.NewMapObjectEncoder()
+		for _, f := range fields {
+			f.AddTo(values)
+		}
+		if id, ok := values.Fields[traceIDKey].(string); ok && isHexID(id, 16) {
+			entry.Record.TraceID = id
+			delete(values.Fields, traceIDKey)
+		}
+		if id, ok := values.Fields[spanIDKey].(string); ok && isHexID(id, 8) {
+			entry.Record.SpanID = id
+			delete(values.Fields, spanIDKey)
+		}
+		if ent.Caller.Defined {
+			values.Fields["code.filepath"] = ent.Caller.File
+			values.Fields["code.lineno"] = ent.Caller.Line
+			if ent.Caller.Function != "" {
+				values.Fields["code.function"] = ent.Caller.Function
+			}
+		}
+		if ent.Stack != "" {
+			values.Fields["code.stacktrace"] = ent.Stack
+		}
+		entry.Record.Attributes = otlpAttributes(values.Fields)
+
+		return json.Marshal(entry) //nolint: wrapcheck // an otlpEntry always marshals.
+	}
+
+	send := func(batch [][]byte) error {
+		logs := resource
+		byScope := map[string]*otlpScopeLogs{}
+		for _, record := range batch {
+			var entry otlpEntry
+			if err := json.Unmarshal(record, &entry); err != nil {
+				continue
+			}
+			scope, ok := byScope[entry.Scope]
+			if !ok {
+				scope = &otlpScopeLogs{}
+				scope.Scope.Name = entry.Scope
+				byScope[entry.Scope] = scope
+				logs.ScopeLogs = append(logs.ScopeLogs, scope)
+			}
+			scope.LogRecords = append(scope.LogRecords, entry.Record)
+		}
+
+		var body []byte
+		contentType := "application/x-protobuf"
+		if cfg.Encoding == otlpJSON {
+			contentType = "application/json"
+			data, err := json.Marshal(map[string][]otlpResourceLogs{"resourceLogs": {logs}})
+			if err != nil {
+				return &permanentError{err: fmt.Errorf("marshal otlp logs: %w", err)}
+			}
+			body = data
+		} else {
+			body = appendProtoMessage(nil, 1, logs.appendProto(nil))
+		}
+		req, err := http.NewRequest(http.MethodPost, target.String(), bytes.NewReader(body))
+		if err != nil {
+			return &permanentError{err: fmt.Errorf("build otlp request: %w", err)}
+		}
+		req.Header.Set("Content-Type", contentType)
+		for k, v := range cfg.Headers {
+			req.Header.Set(k, v)
+		}
+		if u.User != nil {
+			password, _ := u.User.Password()
+			req.SetBasicAuth(u.User.Username(), password)
+		}
+		_, err = doRequest(client, req)
+
+		return err
+	}
+
+	return newBatchSink(u, opts, cfg.Batch.policy(), encode, send)
+}
+
+// otlpResource returns the attributes of the resource of the logs, the
+// service is named after the program when name is empty.
+func otlpResource(name string) []otlpKeyValue {
+	if name == "" {
+		name = "unknown_service:" + filepath.Base(os.Args[0])
+	}
+	attrs := []otlpKeyValue{{Key: "service.name", Value: otlpValue(name)}}
+	if host, err := os.Hostname(); err == nil {
+		attrs = append(attrs, otlpKeyValue{Key: "host.name", Value: otlpValue(host)})
+	}
+
+	return attrs
+}
+
+// otlpSeverity maps lvl to the severity numbers of OpenTelemetry.
+func otlpSeverity(lvl zapcore.Level) int {
+	switch lvl {
+	case zapcore.DebugLevel:
+		return 5 // DEBUG
+	case zapcore.InfoLevel:
+		return 9 // INFO
+	case zapcore.WarnLevel:
+		return 13 // WARN
+	case zapcore.ErrorLevel:
+		return 17 // ERROR
+	case zapcore.DPanicLevel:
+		return 18 // ERROR2
+	case zapcore.PanicLevel:
+		return 19 // ERROR3
+	case zapcore.FatalLevel:
+		return 21 // FATAL
+	default:
+		return 0 // UNSPECIFIED
+	}
+}
+
+// isHexID reports whether id is the hex encoding of n bytes which are not all
+// zero.
+func isHexID(id string, n int) bool {
+	b, err := hex.DecodeString(id)
+
+	return err == nil && len(b) == n && !bytes.Equal(b, make([]byte, n))
+}
+
+func otlpAttributes(fields map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, otlpKeyValue{Key: k, Value: otlpValue(fields[k])})
+	}
+
+	return attrs
+}
+
+// otlpValue converts a value of a zapcore.MapObjectEncoder to an AnyValue,
+// durations are in milliseconds like the other encoders write them.
+func otlpValue(v interface{}) otlpAnyValue {
+	switch v := v.(type) {
+	case string:
+		return otlpAnyValue{StringValue: &v}
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case []byte:
+		return otlpAnyValue{BytesValue: v}
+	case time.Duration:
+		return otlpValue(float64(v) / float64(time.Millisecond))
+	case time.Time:
+		return otlpValue(v.Format(time.RFC3339Nano))
+	case float32:
+		return otlpValue(float64(v))
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return otlpValue(strconv.FormatFloat(v, 'f', -1, 64))
+		}
+
+		return otlpAnyValue{DoubleValue: &v}
+	case []interface{}:
+		values := make([]otlpAnyValue, len(v))
+		for i, e := range v {
+			values[i] = otlpValue(e)
+		}
+
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case map[string]interface{}:
+		return otlpAnyValue{KvlistValue: &otlpKvlist{Values: otlpAttributes(v)}}
+	}
+	if i, ok := otlpInt(v); ok {
+		return otlpAnyValue{IntValue: &i}
+	}
+
+	return otlpValue(fieldString(v))
+}
+
+func otlpInt(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint:
+		return int64(v), uint64(v) <= math.MaxInt64
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	case uintptr:
+		return int64(v), uint64(v) <= math.MaxInt64
+	}
+
+	return 0, false
+}
+
+// The appendProto methods append the protobuf encoding of OTLP, after the
+// field numbers of opentelemetry/proto/logs/v1/logs.proto.
+
+func (l *otlpResourceLogs) appendProto(b []byte) []byte {
+	var resource []byte
+	for _, kv := range l.Resource.Attributes {
+		resource = appendProtoMessage(resource, 1, kv.appendProto(nil))
+	}
+	b = appendProtoMessage(b, 1, resource)
+	for _, scope := range l.ScopeLogs {
+		b = appendProtoMessage(b, 2, scope.appendProto(nil))
+	}
+
+	return b
+}
+
+func (s *otlpScopeLogs) appendProto(b []byte) []byte {
+	b = appendProtoMessage(b, 1, appendProtoMessage(nil, 1, []byte(s.Scope.Name)))
+	for i := range s.LogRecords {
+		b = appendProtoMessage(b, 2, s.LogRecords[i].appendProto(nil))
+	}
+
+	return b
+}
+
+func (r *otlpLogRecord) appendProto(b []byte) []byte {
+	b = appendProtoFixed64(b, 1, r.TimeUnixNano)
+	b = appendProtoVarint(b, 2, uint64(r.SeverityNumber))
+	b = appendProtoMessage(b, 3, []byte(r.SeverityText))
+	b = appendProtoMessage(b, 5, r.Body.appendProto(nil))
+	for _, kv := range r.Attributes {
+		b = appendProtoMessage(b, 6, kv.appendProto(nil))
+	}
+	if id, err := hex.DecodeString(r.TraceID); err == nil && len(id) != 0 {
+		b = appendProtoMessage(b, 9, id)
+	}
+	if id, err := hex.DecodeString(r.SpanID); err == nil && len(id) != 0 {
+		b = appendProtoMessage(b, 10, id)
+	}
+
+	return appendProtoFixed64(b, 11, r.ObservedTimeUnixNano)
+}
+
+func (kv *otlpKeyValue) appendProto(b []byte) []byte {
+	b = appendProtoMessage(b, 1, []byte(kv.Key))
+
+	return appendProtoMessage(b, 2, kv.Value.appendProto(nil))
+}
+
+func (v *otlpAnyValue) appendProto(b []byte) []byte {
+	switch {
+	case v.StringValue != nil:
+		return appendProtoMessage(b, 1, []byte(*v.StringValue))
+	case v.BoolValue != nil:
+		var u uint64
+		if *v.BoolValue {
+			u = 1
+		}
+
+		return appendProtoVarint(b, 2, u)
+	case v.IntValue != nil:
+		return appendProtoVarint(b, 3, uint64(*v.IntValue))
+	case v.DoubleValue != nil:
+		return appendProtoFixed64(b, 4, math.Float64bits(*v.DoubleValue))
+	case v.ArrayValue != nil:
+		return appendProtoMessage(b, 5, v.ArrayValue.appendProto(nil))
+	case v.KvlistValue != nil:
+		return appendProtoMessage(b, 6, v.KvlistValue.appendProto(nil))
+	case v.BytesValue != nil:
+		return appendProtoMessage(b, 7, v.BytesValue)
+	}
+
+	return b
+}
+
+func (a *otlpArrayValue) appendProto(b []byte) []byte {
+	for i := range a.Values {
+		b = appendProtoMessage(b, 1, a.Values[i].appendProto(nil))
+	}
+
+	return b
+}
+
+func (l *otlpKvlist) appendProto(b []byte) []byte {
+	for i := range l.Values {
+		b = appendProtoMessage(b, 1, l.Values[i].appendProto(nil))
+	}
+
+	return b
+}
+
+func appendProtoVarint(b []byte, field int, v uint64) []byte {
+	b = appendUvarint(b, uint64(field)<<3)
+
+	return appendUvarint(b, v)
+}
+
+func appendProtoFixed64(b []byte, field int, v uint64) []byte {
+	b = appendUvarint(b, uint64(field)<<3|1)
+
+	return appendLittleEndian64(b, v)
+}
+
+func appendProtoMessage(b []byte, field int, data []byte) []byte {
+	b = appendUvarint(b, uint64(field)<<3|2)
+	b = appendUvarint(b, uint64(len(data)))
+
+	return append(b, data...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func appendLittleEndian64(b []byte, v uint64) []byte {
+	for i := 0; i < 8; i++ {
+		b = append(b, byte(v>>(8*i)))
+	}
+
+	return b
+}
@@ -0,0 +1,199 @@
+package log_test
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/huanghe314/log"
+)
+
+// otlpServer records the bodies of the requests it receives on /v1/logs.
+type otlpServer struct {
+	mu           sync.Mutex
+	contentTypes []string
+	bodies       [][]byte
+}
+
+func (s *otlpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.URL.Path != "/v1/logs" {
+		w.WriteHeader(http.StatusNotFound)
+
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	s.contentTypes = append(s.contentTypes, r.Header.Get("Content-Type"))
+	s.bodies = append(s.bodies, body)
+}
+
+type otlpValue struct {
+	StringValue string  `json:"stringValue"`
+	IntValue    string  `json:"intValue"`
+	DoubleValue float64 `json:"doubleValue"`
+	ArrayValue  *struct {
+		Values []otlpValue `json:"values"`
+	} `json:"arrayValue"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpLogs struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []otlpAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []struct {
+			Scope struct {
+				Name string `json:"name"`
+			} `json:"scope"`
+			LogRecords []struct {
+				TimeUnixNano   string          `json:"timeUnixNano"`
+				SeverityNumber int             `json:"severityNumber"`
+				SeverityText   string          `json:"severityText"`
+				Body           otlpValue       `json:"body"`
+				Attributes     []otlpAttribute `json:"attributes"`
+				TraceID        string          `json:"traceId"`
+				SpanID         string          `json:"spanId"`
+			} `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+func Test_OTLP_JSON(t *testing.T) {
+	server := &otlpServer{}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	opts := log.NewOptions()
+	opts.Name = "checkout"
+	opts.OutputPaths = []string{"otlp+" + srv.URL}
+	opts.OTLP.Encoding = "json"
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	ctx := log.WithSpanContext(logger.WithContext(context.Background()),
+		"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7")
+	log.FromContext(ctx).Infow("charged", "amount", 12, "tags", []string{"a"})
+	logger.WithName("db").Warn("slow")
+	logger.Flush()
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, []string{"application/json"}, server.contentTypes)
+	var logs otlpLogs
+	assert.Nil(t, json.Unmarshal(server.bodies[0], &logs))
+	assert.Len(t, logs.ResourceLogs, 1)
+	host, _ := os.Hostname()
+	assert.Equal(t, []otlpAttribute{
+		{Key: "service.name", Value: otlpValue{StringValue: "checkout"}},
+		{Key: "host.name", Value: otlpValue{StringValue: host}},
+	}, logs.ResourceLogs[0].Resource.Attributes)
+
+	scopes := logs.ResourceLogs[0].ScopeLogs
+	assert.Len(t, scopes, 2)
+	assert.Equal(t, "", scopes[0].Scope.Name)
+	charged := scopes[0].LogRecords[0]
+	assert.NotEmpty(t, charged.TimeUnixNano)
+	assert.Equal(t, 9, charged.SeverityNumber)
+	assert.Equal(t, "INFO", charged.SeverityText)
+	assert.Equal(t, "charged", charged.Body.StringValue)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", charged.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", charged.SpanID)
+	assert.Len(t, charged.Attributes, 2)
+	assert.Equal(t, otlpAttribute{Key: "amount", Value: otlpValue{IntValue: "12"}}, charged.Attributes[0])
+	assert.Equal(t, "tags", charged.Attributes[1].Key)
+	assert.Equal(t, "a", charged.Attributes[1].Value.ArrayValue.Values[0].StringValue)
+
+	assert.Equal(t, "db", scopes[1].Scope.Name)
+	assert.Equal(t, 13, scopes[1].LogRecords[0].SeverityNumber)
+	assert.Empty(t, scopes[1].LogRecords[0].TraceID)
+}
+
+// protoFields decodes the fields of a protobuf message, the values of the
+// length delimited fields are kept as bytes.
+func protoFields(t *testing.T, b []byte) map[int][]interface{} {
+	t.Helper()
+	fields := map[int][]interface{}{}
+	for len(b) != 0 {
+		tag, n := binary.Uvarint(b)
+		b = b[n:]
+		field := int(tag >> 3)
+		switch tag & 7 {
+		case 0:
+			v, n := binary.Uvarint(b)
+			fields[field] = append(fields[field], v)
+			b = b[n:]
+		case 1:
+			fields[field] = append(fields[field], binary.LittleEndian.Uint64(b))
+			b = b[8:]
+		case 2:
+			size, n := binary.Uvarint(b)
+			fields[field] = append(fields[field], b[n:n+int(size)])
+			b = b[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type of %d", tag)
+		}
+	}
+
+	return fields
+}
+
+func Test_OTLP_Protobuf(t *testing.T) {
+	server := &otlpServer{}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{"otlp+" + srv.URL + "/v1/logs"}
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	// the ids of an OpenTelemetry span take precedence over WithSpanContext.
+	span := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:  trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	})
+	ctx := log.WithSpanContext(logger.WithName("billing").WithContext(context.Background()),
+		"00000000000000000000000000000001", "0000000000000001")
+	log.FromContext(trace.ContextWithSpanContext(ctx, span)).Warnw("failed", "retries", 3)
+	logger.Flush()
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, []string{"application/x-protobuf"}, server.contentTypes)
+	// LogsData.resource_logs, ResourceLogs.scope_logs, ScopeLogs.log_records.
+	resourceLogs := protoFields(t, protoFields(t, server.bodies[0])[1][0].([]byte))
+	resource := protoFields(t, resourceLogs[1][0].([]byte))
+	serviceName := protoFields(t, resource[1][0].([]byte))
+	assert.Equal(t, []byte("service.name"), serviceName[1][0])
+	scopeLogs := protoFields(t, resourceLogs[2][0].([]byte))
+	record := protoFields(t, scopeLogs[2][0].([]byte))
+
+	assert.Equal(t, uint64(13), record[2][0])
+	assert.Equal(t, []byte("WARN"), record[3][0])
+	assert.Equal(t, []byte("failed"), protoFields(t, record[5][0].([]byte))[1][0])
+	assert.Equal(t, span.TraceID().String(), hex.EncodeToString(record[9][0].([]byte)))
+	assert.Equal(t, span.SpanID().String(), hex.EncodeToString(record[10][0].([]byte)))
+	assert.NotZero(t, record[1][0])
+	retries := protoFields(t, record[6][0].([]byte))
+	assert.Equal(t, []byte("retries"), retries[1][0])
+	assert.Equal(t, uint64(3), protoFields(t, retries[2][0].([]byte))[3][0])
+	assert.Equal(t, []byte("billing"), protoFields(t, scopeLogs[1][0].([]byte))[1][0])
+}
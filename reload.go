@@ -0,0 +1,223 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// WatchOptionsFile polls the options file at path every interval and applies
+// it to the global logger with ReloadOptions whenever its content changes. It
+// blocks until ctx is done. Files mounted from a Kubernetes ConfigMap are
+// supported since the content, not the modification time, is compared.
+func WatchOptionsFile(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var sum []byte
+	for {
+		sum = reloadIfChanged(path, sum)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reloadIfChanged reloads the options file at path when the checksum of its
+// content differs from lastSum, it returns the checksum of the content seen.
+func reloadIfChanged(path string, lastSum []byte) []byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		Errorw("Failed to read log options", "path", path, "error", err)
+
+		return lastSum
+	}
+	sum := sha256.Sum256(data)
+	if bytes.Equal(sum[:], lastSum) {
+		return lastSum
+	}
+
+	opts, err := LoadOptionsFromFile(path)
+	if err != nil {
+		Errorw("Failed to load log options", "path", path, "error", err)
+
+		return sum[:]
+	}
+	if err := ReloadOptions(opts); err != nil {
+		Errorw("Failed to reload log options", "path", path, "error", err)
+	}
+
+	return sum[:]
+}
+
+// ReloadOptions applies opts to the global logger. When only the levels
+// changed they are adjusted in place, otherwise the logger is rebuilt and
+// swapped in, keeping the sinks whose path and rotation options did not
+// change. Loggers obtained from the global logger before the reload write to
+// the new sinks, while zap options such as the caller or the stacktrace level
+// only apply to loggers obtained afterwards. Levels set at runtime are kept
+// unless opts change them. The previous logger stays in place when opts are
+// invalid or the new logger cannot be built.
+func ReloadOptions(opts *Options) error {
+	if errs := opts.Validate(); len(errs) != 0 {
+		return multierr.Combine(errs...)
+	}
+
+	mu.Lock()
+	changes := diffOptions(_options, opts)
+	if len(changes) == 0 {
+		mu.Unlock()
+
+		return nil
+	}
+
+	var err error
+	if levelsOnly(changes) {
+		_logger.Load().levels.reload(_options, opts)
+		_options = opts
+	} else {
+		err = initLocked(opts, _options)
+	}
+	mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	Infow("Reloaded log options", "changes", changes)
+
+	return nil
+}
+
+// levelsOnly reports whether changes only concern the levels, which are
+// adjusted in place.
+func levelsOnly(changes []string) bool {
+	for _, change := range changes {
+		if !strings.HasPrefix(change, "level:") && !strings.HasPrefix(change, "levels:") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// diffOptions describes the fields which differ between prev and next, fields
+// are named by their json tag and their secrets are masked.
+func diffOptions(prev, next *Options) []string {
+	if prev == nil {
+		prev = &Options{}
+	}
+	oldValue, newValue := reflect.ValueOf(prev).Elem(), reflect.ValueOf(next).Elem()
+	oldPrinted, newPrinted := reflect.ValueOf(prev.redacted()).Elem(), reflect.ValueOf(next.redacted()).Elem()
+
+	var changes []string
+	for i := 0; i < oldValue.NumField(); i++ {
+		field := oldValue.Type().Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", name,
+				oldPrinted.Field(i).Interface(), newPrinted.Field(i).Interface()))
+		}
+	}
+
+	return changes
+}
+
+// swapCore is a core whose target is replaced when the global logger is
+// rebuilt, so that the loggers derived from the global logger before a reload
+// write to the sinks of the reloaded one instead of the closed ones.
+type swapCore struct {
+	target *atomic.Value // *swapTarget
+	fields []zapcore.Field
+	// cached holds the target with fields added, it is rebuilt whenever the
+	// target changes.
+	cached *atomic.Value // *swapTarget
+}
+
+// swapTarget boxes a core so that the atomic values always store the same
+// concrete type.
+type swapTarget struct {
+	core zapcore.Core
+	// from is the target the core was derived from.
+	from *swapTarget
+}
+
+func newSwapCore() *swapCore {
+	c := &swapCore{target: &atomic.Value{}, cached: &atomic.Value{}}
+	c.target.Store(&swapTarget{core: zapcore.NewNopCore()})
+
+	return c
+}
+
+// swap makes core the target of c and of every core derived from c.
+func (c *swapCore) swap(core zapcore.Core) { c.target.Store(&swapTarget{core: core}) }
+
+func (c *swapCore) current() zapcore.Core {
+	target := c.target.Load().(*swapTarget)
+	if len(c.fields) == 0 {
+		return target.core
+	}
+	if cached, ok := c.cached.Load().(*swapTarget); ok && cached.from == target {
+		return cached.core
+	}
+	cached := &swapTarget{core: target.core.With(c.fields), from: target}
+	c.cached.Store(cached)
+
+	return cached.core
+}
+
+func (c *swapCore) Enabled(lvl zapcore.Level) bool { return c.current().Enabled(lvl) }
+
+func (c *swapCore) With(fields []zapcore.Field) zapcore.Core {
+	all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	all = append(all, c.fields...)
+	all = append(all, fields...)
+
+	return &swapCore{target: c.target, fields: all, cached: &atomic.Value{}}
+}
+
+func (c *swapCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return c.current().Check(ent, ce)
+}
+
+func (c *swapCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.current().Write(ent, fields) //nolint: wrapcheck // keep the error of the target.
+}
+
+func (c *swapCore) Sync() error {
+	return c.current().Sync() //nolint: wrapcheck // keep the error of the target.
+}
+
+// swapSyncer is the error output of the global logger, its target is replaced
+// together with the target of the swapCore.
+type swapSyncer struct {
+	target atomic.Value // *swapTarget
+}
+
+func (s *swapSyncer) swap(w zapcore.WriteSyncer) { s.target.Store(&swapSyncerTarget{w}) }
+
+// swapSyncerTarget boxes a write syncer for swapSyncer.
+type swapSyncerTarget struct {
+	zapcore.WriteSyncer
+}
+
+func (s *swapSyncer) Write(p []byte) (int, error) {
+	return s.target.Load().(*swapSyncerTarget).Write(p) //nolint: wrapcheck // keep the error of the target.
+}
+
+func (s *swapSyncer) Sync() error {
+	return s.target.Load().(*swapSyncerTarget).Sync() //nolint: wrapcheck // keep the error of the target.
+}
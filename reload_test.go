@@ -0,0 +1,103 @@
+package log_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/huanghe314/log"
+)
+
+func Test_WatchOptionsFile(t *testing.T) {
+	defer log.Init(log.NewOptions())
+
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	jsonPath := filepath.Join(dir, "app.json.log")
+	optsPath := filepath.Join(dir, "log.yaml")
+	assert.Nil(t, os.WriteFile(optsPath, []byte("output-paths: ["+logPath+"]\n"), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go log.WatchOptionsFile(ctx, optsPath, 10*time.Millisecond)
+
+	assert.Eventually(t, func() bool {
+		return log.GetOptions().OutputPaths[0] == logPath
+	}, time.Second, 10*time.Millisecond)
+	log.Debug("debug disabled")
+
+	assert.Nil(t, os.WriteFile(optsPath, []byte("level: debug\noutput-paths: ["+logPath+"]\n"), 0o600))
+	assert.Eventually(t, func() bool {
+		return log.GetLevel() == log.DebugLevel
+	}, time.Second, 10*time.Millisecond)
+	log.Debug("debug enabled")
+
+	assert.Nil(t, os.WriteFile(optsPath, []byte("level: debug\nformat: json\noutput-paths: ["+jsonPath+"]\n"), 0o600))
+	assert.Eventually(t, func() bool {
+		return log.GetOptions().Format == "json"
+	}, time.Second, 10*time.Millisecond)
+	log.Info("json format")
+
+	// invalid options keep the current logger.
+	assert.Nil(t, os.WriteFile(optsPath, []byte("level: loud\n"), 0o600))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "json", log.GetOptions().Format)
+	log.Flush()
+
+	data, err := os.ReadFile(logPath)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "debug disabled")
+	assert.Contains(t, string(data), "debug enabled")
+	assert.Contains(t, string(data), "Reloaded log options")
+
+	data, err = os.ReadFile(jsonPath)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"msg":"json format"`)
+}
+
+func Test_ReloadOptions_InvalidLevel(t *testing.T) {
+	defer log.Init(log.NewOptions())
+	log.Init(log.NewOptions())
+
+	opts := log.NewOptions()
+	opts.Level = "loud"
+	assert.NotNil(t, log.ReloadOptions(opts))
+	assert.Equal(t, "info", log.GetOptions().Level)
+	assert.Equal(t, log.InfoLevel, log.GetLevel())
+}
+
+func Test_ReloadOptions_DerivedLoggers(t *testing.T) {
+	defer log.Init(log.NewOptions())
+
+	dir := t.TempDir()
+	oldPath, newPath := filepath.Join(dir, "old.log"), filepath.Join(dir, "new.log")
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{oldPath}
+	log.Init(opts)
+
+	derived := log.WithName("db").WithValues("shard", 1)
+	derived.SetLevel(log.DebugLevel)
+	zapLogger := log.ZapLogger()
+
+	opts = log.NewOptions()
+	opts.OutputPaths = []string{newPath}
+	opts.Format = "json"
+	assert.Nil(t, log.ReloadOptions(opts))
+
+	derived.Debug("derived after reload")
+	zapLogger.Info("zap after reload")
+	log.Flush()
+
+	assert.Equal(t, log.DebugLevel, log.WithName("db").GetLevel())
+	data, err := os.ReadFile(newPath)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"msg":"derived after reload","shard":1`)
+	assert.Contains(t, string(data), `"msg":"zap after reload"`)
+	data, err = os.ReadFile(oldPath)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "after reload")
+}
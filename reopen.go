@@ -0,0 +1,127 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"go.uber.org/multierr"
+)
+
+var (
+	_reopenMu       sync.Mutex
+	_reopenFiles    = map[*reopenFile]struct{}{}
+	_reopenOnSignal sync.Once
+)
+
+// reopenFile is a WriteSyncer appending to a path which can be closed and
+// opened again, so that an external tool like logrotate can move the file away.
+type reopenFile struct {
+	path string
+	mu   sync.RWMutex
+	file *os.File
+}
+
+// openReopenFile opens path for appending and registers it for Reopen. The
+// first call starts reopening the files on SIGHUP.
+func openReopenFile(path string) (*reopenFile, error) {
+	f := &reopenFile{path: path}
+	if err := f.Reopen(); err != nil {
+		return nil, err
+	}
+
+	_reopenMu.Lock()
+	_reopenFiles[f] = struct{}{}
+	_reopenMu.Unlock()
+	_reopenOnSignal.Do(func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, syscall.SIGHUP)
+		go func() {
+			for range ch {
+				if err := Reopen(); err != nil {
+					Errorw("Failed to reopen log files", "error", err)
+				}
+			}
+		}()
+	})
+
+	return f, nil
+}
+
+func (f *reopenFile) Write(p []byte) (int, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.file == nil {
+		return 0, fmt.Errorf("write %s: file already closed", f.path)
+	}
+
+	return f.file.Write(p) //nolint: wrapcheck // keep the error of the file.
+}
+
+func (f *reopenFile) Sync() error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.file == nil {
+		return nil
+	}
+
+	return f.file.Sync() //nolint: wrapcheck // keep the error of the file.
+}
+
+// Reopen closes the file and opens its path again. Writes wait until the new
+// file is open.
+func (f *reopenFile) Reopen() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return fmt.Errorf("can't make directories for logfile: %w", err)
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("can't open logfile: %w", err)
+	}
+
+	f.mu.Lock()
+	old := f.file
+	f.file = file
+	f.mu.Unlock()
+	if old != nil {
+		return old.Close() //nolint: wrapcheck // keep the error of the file.
+	}
+
+	return nil
+}
+
+// Close closes the file and stops reopening it.
+func (f *reopenFile) Close() error {
+	_reopenMu.Lock()
+	delete(_reopenFiles, f)
+	_reopenMu.Unlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+
+	return err //nolint: wrapcheck // keep the error of the file.
+}
+
+// Reopen closes and reopens every log file opened with Options.ExternalRotation,
+// it is called on SIGHUP. Use it after an external tool rotated the files.
+func Reopen() error {
+	_reopenMu.Lock()
+	defer _reopenMu.Unlock()
+
+	var errs []error
+	for f := range _reopenFiles {
+		if err := f.Reopen(); err != nil {
+			errs = append(errs, fmt.Errorf("reopen %q: %w", f.path, err))
+		}
+	}
+
+	return multierr.Combine(errs...)
+}
@@ -0,0 +1,63 @@
+package log_test
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/huanghe314/log"
+)
+
+func Test_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	rotated := filepath.Join(dir, "app.log.1")
+	opts := log.NewOptions()
+	opts.ExternalRotation = true
+	opts.OutputPaths = []string{path}
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.Info("concurrent")
+			}
+		}()
+	}
+	logger.Info("before")
+	assert.Nil(t, os.Rename(path, rotated))
+	logger.Info("moved")
+	assert.Nil(t, log.Reopen())
+	wg.Wait()
+	logger.Info("reopened")
+
+	data, err := os.ReadFile(rotated)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "before")
+	assert.Contains(t, string(data), "moved")
+	assert.NotContains(t, string(data), "reopened")
+	data, err = os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "reopened")
+
+	// logrotate sends SIGHUP after moving the files.
+	assert.Nil(t, os.Rename(path, rotated))
+	process, err := os.FindProcess(os.Getpid())
+	assert.Nil(t, err)
+	assert.Nil(t, process.Signal(syscall.SIGHUP))
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,398 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	hourlyInterval = "hourly"
+	dailyInterval  = "daily"
+
+	megabyte = 1024 * 1024
+)
+
+// timeRotator is a WriteSyncer which starts a new file at the beginning of
+// every interval and, when a max size is set, whenever the current file would
+// grow beyond it. File names are built from a pattern in which %Y, %m, %d and
+// %H are replaced by the start of the interval. Files rolled over by size get
+// an index inserted before the extension, e.g. app-2026101614.1.log.
+type timeRotator struct {
+	pattern string
+	rotated *regexp.Regexp
+	options rotationOptions
+	now     func() time.Time
+
+	mu       sync.Mutex
+	file     *os.File
+	filename string
+	period   time.Time
+	index    int
+	size     int64
+
+	millOnce sync.Once
+	millCh   chan struct{}
+	done     chan struct{}
+}
+
+func newTimeRotator(path string, options rotationOptions) *timeRotator {
+	pattern := rotationPattern(path, options.interval)
+
+	return &timeRotator{
+		pattern: pattern,
+		rotated: rotatedPattern(pattern),
+		options: options,
+		now:     time.Now,
+		millCh:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// rotationPattern returns path when it already holds time verbs, otherwise
+// the verbs matching interval are inserted before the extension of path.
+func rotationPattern(path string, interval string) string {
+	if strings.Contains(path, "%") {
+		return path
+	}
+	suffix := "-%Y%m%d%H"
+	if interval == dailyInterval {
+		suffix = "-%Y%m%d"
+	}
+	ext := filepath.Ext(path)
+
+	return strings.TrimSuffix(path, ext) + suffix + ext
+}
+
+var _patternVerbs = map[byte]func(t time.Time) string{
+	'Y': func(t time.Time) string { return fmt.Sprintf("%04d", t.Year()) },
+	'm': func(t time.Time) string { return fmt.Sprintf("%02d", t.Month()) },
+	'd': func(t time.Time) string { return fmt.Sprintf("%02d", t.Day()) },
+	'H': func(t time.Time) string { return fmt.Sprintf("%02d", t.Hour()) },
+	'M': func(t time.Time) string { return fmt.Sprintf("%02d", t.Minute()) },
+	'%': func(time.Time) string { return "%" },
+}
+
+// rotatedPattern returns a regexp matching the names of the files written for
+// pattern: its verbs replaced by digits, with an optional index before the
+// extension and an optional .gz suffix.
+func rotatedPattern(pattern string) *regexp.Regexp {
+	quote := func(s string) string {
+		var b strings.Builder
+		for i := 0; i < len(s); i++ {
+			if s[i] == '%' && i+1 < len(s) {
+				if _, ok := _patternVerbs[s[i+1]]; ok {
+					switch s[i+1] {
+					case '%':
+						b.WriteString("%")
+					case 'Y':
+						b.WriteString(`\d{4}`)
+					default:
+						b.WriteString(`\d{2}`)
+					}
+					i++
+
+					continue
+				}
+			}
+			b.WriteString(regexp.QuoteMeta(s[i : i+1]))
+		}
+
+		return b.String()
+	}
+	ext := filepath.Ext(pattern)
+
+	return regexp.MustCompile("^" + quote(strings.TrimSuffix(pattern, ext)) + `(\.\d+)?` + quote(ext) + `(\.gz)?$`)
+}
+
+// expandPattern replaces the verbs of pattern by calling replace with them.
+func expandPattern(pattern string, replace func(verb byte) string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '%' && i+1 < len(pattern) {
+			if _, ok := _patternVerbs[pattern[i+1]]; ok {
+				b.WriteString(replace(pattern[i+1]))
+				i++
+
+				continue
+			}
+		}
+		b.WriteByte(pattern[i])
+	}
+
+	return b.String()
+}
+
+// withIndex inserts index before the extension of name.
+func withIndex(name string, index int) string {
+	if index == 0 {
+		return name
+	}
+	ext := filepath.Ext(name)
+
+	return strings.TrimSuffix(name, ext) + "." + strconv.Itoa(index) + ext
+}
+
+func (r *timeRotator) periodStart(t time.Time) time.Time {
+	if !r.options.localTime {
+		t = t.UTC()
+	}
+	hour := t.Hour()
+	if r.options.interval == dailyInterval {
+		hour = 0
+	}
+
+	return time.Date(t.Year(), t.Month(), t.Day(), hour, 0, 0, 0, t.Location())
+}
+
+func (r *timeRotator) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	period := r.periodStart(r.now())
+	switch {
+	case r.file == nil || !period.Equal(r.period):
+		if err := r.openPeriod(period); err != nil {
+			return 0, err
+		}
+	case r.options.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > int64(r.options.maxSize)*megabyte:
+		if err := r.openIndex(r.index + 1); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+
+	return n, err //nolint: wrapcheck // keep the error of the file.
+}
+
+// openPeriod opens the last file written in period, appending to it.
+func (r *timeRotator) openPeriod(period time.Time) error {
+	r.period = period
+	r.filename = expandPattern(r.pattern, func(verb byte) string {
+		return _patternVerbs[verb](period)
+	})
+	index := 0
+	for rotatedExists(withIndex(r.filename, index+1)) {
+		index++
+	}
+	// a compressed file is done with, the period goes on with the next one.
+	if _, err := os.Stat(withIndex(r.filename, index) + ".gz"); err == nil {
+		index++
+	}
+
+	return r.openIndex(index)
+}
+
+// rotatedExists tells whether the file name exists, compressed or not.
+func rotatedExists(name string) bool {
+	for _, n := range []string{name, name + ".gz"} {
+		if _, err := os.Stat(n); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *timeRotator) openIndex(index int) error {
+	name := withIndex(r.filename, index)
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return fmt.Errorf("can't make directories for new logfile: %w", err)
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("can't open new logfile: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+
+		return fmt.Errorf("can't stat new logfile: %w", err)
+	}
+	if r.file != nil {
+		_ = r.file.Close()
+	}
+	r.file, r.index, r.size = f, index, info.Size()
+	r.startMill()
+
+	return nil
+}
+
+func (r *timeRotator) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+
+	return r.file.Sync() //nolint: wrapcheck // keep the error of the file.
+}
+
+// Close closes the current file and stops the cleanup of old files.
+func (r *timeRotator) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.millOnce.Do(func() {})
+	select {
+	case <-r.done:
+	default:
+		close(r.done)
+	}
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+
+	return err //nolint: wrapcheck // keep the error of the file.
+}
+
+// startMill starts the cleanup goroutine once and asks it to run.
+func (r *timeRotator) startMill() {
+	r.millOnce.Do(func() {
+		go r.millRun()
+	})
+	select {
+	case r.millCh <- struct{}{}:
+	default:
+	}
+}
+
+func (r *timeRotator) millRun() {
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-r.millCh:
+			_ = r.mill()
+		}
+	}
+}
+
+type rotatedFile struct {
+	name    string
+	modTime time.Time
+}
+
+// mill compresses the rotated files and removes the ones beyond max backups
+// or older than max age.
+func (r *timeRotator) mill() error {
+	r.mu.Lock()
+	current := ""
+	if r.file != nil {
+		current = r.file.Name()
+	}
+	r.mu.Unlock()
+
+	files, err := r.rotatedFiles(current)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	cutoff := r.now().Add(-time.Duration(r.options.maxAge) * 24 * time.Hour)
+	for i, f := range files {
+		if (r.options.maxBackups > 0 && i >= r.options.maxBackups) ||
+			(r.options.maxAge > 0 && f.modTime.Before(cutoff)) {
+			if err := os.Remove(f.name); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+
+			continue
+		}
+		if r.options.compress && !strings.HasSuffix(f.name, ".gz") {
+			if err := compressFile(f.name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("clean rotated logfiles: %v", errs)
+	}
+
+	return nil
+}
+
+// rotatedFiles returns the files written for the pattern except current,
+// newest first. The glob of the pattern also lists unrelated files, such as
+// app-old-backup.log for app-%Y%m%d.log, they are filtered out by rotated.
+func (r *timeRotator) rotatedFiles(current string) ([]rotatedFile, error) {
+	glob := expandPattern(r.pattern, func(verb byte) string {
+		if verb == '%' {
+			return "%"
+		}
+
+		return "*"
+	})
+	var files []rotatedFile
+	for _, g := range []string{glob, glob + ".gz"} {
+		names, err := filepath.Glob(g)
+		if err != nil {
+			return nil, fmt.Errorf("list rotated logfiles: %w", err)
+		}
+		for _, name := range names {
+			if name == current || !r.rotated.MatchString(name) {
+				continue
+			}
+			info, err := os.Stat(name)
+			if err != nil {
+				continue
+			}
+			files = append(files, rotatedFile{name: name, modTime: info.ModTime()})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+
+	return files, nil
+}
+
+// compressFile compresses name with gzip and removes it. The compressed file
+// keeps the modification time of name so that it ages the same way.
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("open logfile: %w", err)
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return fmt.Errorf("stat logfile: %w", err)
+	}
+
+	// an existing compressed file is never overwritten.
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open compressed logfile: %w", err)
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		_ = dst.Close()
+
+		return fmt.Errorf("compress logfile: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		_ = dst.Close()
+
+		return fmt.Errorf("compress logfile: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("close compressed logfile: %w", err)
+	}
+	if err := os.Chtimes(name+".gz", info.ModTime(), info.ModTime()); err != nil {
+		return fmt.Errorf("keep logfile time: %w", err)
+	}
+
+	return os.Remove(name) //nolint: wrapcheck // keep the error of the file.
+}
@@ -0,0 +1,149 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func Test_rotationPattern(t *testing.T) {
+	assert.Equal(t, "logs/app-%Y%m%d%H.log", rotationPattern("logs/app.log", hourlyInterval))
+	assert.Equal(t, "logs/app-%Y%m%d", rotationPattern("logs/app", dailyInterval))
+	assert.Equal(t, "logs/%Y/app-%d.log", rotationPattern("logs/%Y/app-%d.log", dailyInterval))
+}
+
+func Test_rotatedPattern(t *testing.T) {
+	re := rotatedPattern(filepath.Join("logs", "app-%Y%m%d.log"))
+	for name, want := range map[string]bool{
+		"app-20261016.log":    true,
+		"app-20261016.2.log":  true,
+		"app-20261016.log.gz": true,
+		"app-old-backup.log":  false,
+		"app-2026101.log":     false,
+		"app-20261016.log.1":  false,
+	} {
+		assert.Equal(t, want, re.MatchString(filepath.Join("logs", name)), name)
+	}
+}
+
+func Test_timeRotator_Interval(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{t: time.Date(2026, 10, 16, 14, 59, 0, 0, time.UTC)}
+	r := newTimeRotator(filepath.Join(dir, "app.log"), rotationOptions{interval: hourlyInterval})
+	r.now = clock.now
+	defer r.Close()
+
+	_, err := r.Write([]byte("first\n"))
+	assert.Nil(t, err)
+	clock.t = clock.t.Add(time.Minute)
+	_, err = r.Write([]byte("second\n"))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"app-2026101614.log", "app-2026101615.log"}, listDir(t, dir))
+	data, err := os.ReadFile(filepath.Join(dir, "app-2026101615.log"))
+	assert.Nil(t, err)
+	assert.Equal(t, "second\n", string(data))
+}
+
+func Test_timeRotator_Size(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{t: time.Date(2026, 10, 16, 14, 0, 0, 0, time.UTC)}
+	r := newTimeRotator(filepath.Join(dir, "app-%Y%m%d.log"), rotationOptions{interval: dailyInterval, maxSize: 1})
+	r.now = clock.now
+	defer r.Close()
+
+	chunk := make([]byte, megabyte/2+1)
+	for i := 0; i < 3; i++ {
+		_, err := r.Write(chunk)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, []string{"app-20261016.1.log", "app-20261016.2.log", "app-20261016.log"}, listDir(t, dir))
+
+	// a new rotator continues with the last file of the period.
+	r2 := newTimeRotator(filepath.Join(dir, "app-%Y%m%d.log"), rotationOptions{interval: dailyInterval, maxSize: 1})
+	r2.now = clock.now
+	defer r2.Close()
+	_, err := r2.Write([]byte("more"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, r2.index)
+}
+
+func Test_timeRotator_Compressed(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{t: time.Date(2026, 10, 16, 14, 0, 0, 0, time.UTC)}
+	for _, name := range []string{"app-20261016.log.gz", "app-20261016.1.log.gz"} {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte("old"), 0o600))
+	}
+
+	// the compressed files are skipped rather than written again.
+	r := newTimeRotator(filepath.Join(dir, "app-%Y%m%d.log"), rotationOptions{interval: dailyInterval, maxSize: 1})
+	r.now = clock.now
+	defer r.Close()
+	_, err := r.Write([]byte("new"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, r.index)
+
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "app-20261016.2.log.gz"), []byte("old"), 0o600))
+	assert.NotNil(t, compressFile(filepath.Join(dir, "app-20261016.2.log")))
+	data, err := os.ReadFile(filepath.Join(dir, "app-20261016.2.log.gz"))
+	assert.Nil(t, err)
+	assert.Equal(t, "old", string(data))
+}
+
+func Test_timeRotator_Mill(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{t: time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)}
+	r := newTimeRotator(filepath.Join(dir, "app.log"), rotationOptions{
+		interval:   hourlyInterval,
+		maxBackups: 2,
+		maxAge:     1,
+		compress:   true,
+	})
+	r.now = clock.now
+	r.millOnce.Do(func() {}) // run mill by hand.
+	defer r.Close()
+	// files matching the glob of the pattern but not written for it are kept.
+	for _, name := range []string{"app-old-backup.log", "app-2026.log"} {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), nil, 0o600))
+	}
+
+	for i := 0; i < 4; i++ {
+		_, err := r.Write([]byte("line\n"))
+		assert.Nil(t, err)
+		// files are ordered by modification time.
+		assert.Nil(t, os.Chtimes(r.file.Name(), clock.t, clock.t))
+		clock.t = clock.t.Add(time.Hour)
+	}
+	assert.Nil(t, r.mill())
+	assert.Equal(t, []string{
+		"app-2026.log", "app-2026101611.log.gz", "app-2026101612.log.gz", "app-2026101613.log", "app-old-backup.log",
+	}, listDir(t, dir))
+
+	clock.t = clock.t.Add(48 * time.Hour)
+	assert.Nil(t, r.mill())
+	assert.Equal(t, []string{"app-2026.log", "app-2026101613.log", "app-old-backup.log"}, listDir(t, dir))
+}
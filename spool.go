@@ -0,0 +1,613 @@
+package log
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultSpoolSizeInMB = 512
+
+	spoolSuffix     = ".spool"
+	spoolOffsetFile = "offset"
+	// a record is the length and the crc32 of the entry, followed by it.
+	spoolHeaderSize = 8
+	// spoolOffsetInterval throttles the saves of the offset while entries are
+	// delivered, the entries delivered since the last save are sent again
+	// after a crash.
+	spoolOffsetInterval = time.Second
+)
+
+// SpoolOptions sets a spool directory for a remote output path. Its entries
+// are appended to segment files in the directory and replayed in order once
+// the remote end is reachable, across restarts of the process. Entries may be
+// delivered twice after a crash, they are not lost. A directory is used by a
+// single spool of the process, a reload hands it over to the new sink.
+type SpoolOptions struct {
+	Dir string `json:"dir"            mapstructure:"dir"`
+	// MaxSizeInMB bounds the size of the segment files, the oldest segment is
+	// evicted beyond it. 0 means 512.
+	MaxSizeInMB int `json:"max-size-in-mb" mapstructure:"max-size-in-mb"`
+}
+
+// validateSpools checks the spools of o, which are set for the remote output
+// paths of o only.
+func validateSpools(o *Options) []error {
+	spools := o.Spools
+	paths := make([]string, 0, len(spools))
+	for path := range spools {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var errs []error
+	dirs := map[string]string{}
+	for _, path := range paths {
+		spool := spools[path]
+		if !isRemoteOutputPath(path, o) {
+			errs = append(errs, fmt.Errorf("spool of %q which is not a remote output path", path))
+		}
+		if spool.Dir == "" {
+			errs = append(errs, fmt.Errorf("missing spool dir of %q", path))
+
+			continue
+		}
+		if spool.MaxSizeInMB < 0 {
+			errs = append(errs, fmt.Errorf("negative spool max size in MB of %q: %d", path, spool.MaxSizeInMB))
+		}
+		dir := filepath.Clean(spool.Dir)
+		if other, ok := dirs[dir]; ok {
+			errs = append(errs, fmt.Errorf("spool dir %q of %q is already used by %q", spool.Dir, path, other))
+		}
+		dirs[dir] = path
+	}
+
+	return errs
+}
+
+// isRemoteOutputPath tells whether path is one of the output paths of o whose
+// sink buffers its entries.
+func isRemoteOutputPath(path string, o *Options) bool {
+	u, err := url.Parse(path)
+	if err != nil || !_sinkSchemes[u.Scheme].buffered {
+		return false
+	}
+	for _, output := range append(append([]string(nil), o.OutputPaths...), o.ErrorOutputPaths...) {
+		if ou, err := url.Parse(output); err == nil && ou.String() == u.String() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// spoolOf returns the spool options set for the output path u.
+func spoolOf(u *url.URL, opts *Options) (SpoolOptions, bool) {
+	for path, spool := range opts.Spools {
+		if pu, err := url.Parse(path); err == nil && pu.String() == u.String() {
+			return spool, true
+		}
+	}
+
+	return SpoolOptions{}, false
+}
+
+// openBuffer opens the spool of the output path u, or a memory buffer of
+// memSize bytes when it has none.
+func openBuffer(u *url.URL, opts *Options, memSize int) (entryBuffer, error) {
+	options, ok := spoolOf(u, opts)
+	if !ok {
+		return &memoryBuffer{maxSize: memSize}, nil
+	}
+	maxSize := options.MaxSizeInMB
+	if maxSize == 0 {
+		maxSize = defaultSpoolSizeInMB
+	}
+
+	return openSpool(u.String(), options.Dir, int64(maxSize)*megabyte)
+}
+
+var (
+	_spoolsMu sync.Mutex
+	_spools   = map[*spool]struct{}{}
+)
+
+// SpoolStat describes the depth of the spool of an output path.
+type SpoolStat struct {
+	Path string
+	// Entries and Bytes count the entries waiting for delivery.
+	Entries  int64
+	Bytes    int64
+	Segments int64
+}
+
+// SpoolStats returns the depth of the open spools sorted by output path.
+func SpoolStats() []SpoolStat {
+	_spoolsMu.Lock()
+	stats := make([]SpoolStat, 0, len(_spools))
+	for s := range _spools {
+		stats = append(stats, SpoolStat{
+			Path:     s.path,
+			Entries:  atomic.LoadInt64(&s.entries),
+			Bytes:    atomic.LoadInt64(&s.bytes),
+			Segments: atomic.LoadInt64(&s.segmentCount),
+		})
+	}
+	_spoolsMu.Unlock()
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Path < stats[j].Path })
+
+	return stats
+}
+
+type spoolSegment struct {
+	id   uint64
+	size int64
+}
+
+// spool is an entryBuffer appending the entries to segment files. The
+// position of the oldest entry is saved to an offset file, replaced
+// atomically, when a segment is done with, on sync and close, and at most
+// every spoolOffsetInterval while entries are delivered.
+type spool struct {
+	path        string
+	dir         string
+	maxSize     int64
+	segmentSize int64
+
+	segments []spoolSegment
+	writer   *os.File
+	reader   *os.File
+	readPos  int64
+	// readID numbers the record at readPos.
+	readID uint64
+	// savedPos is the read position in the first segment last saved, at
+	// savedAt.
+	savedPos int64
+	savedAt  time.Time
+
+	// entries, bytes and segmentCount are read by SpoolStats.
+	entries      int64
+	bytes        int64
+	segmentCount int64
+}
+
+// openSpool opens the spool in dir, which must not be used by another open
+// spool of the process.
+func openSpool(path, dir string, maxSize int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("can't make spool directory: %w", err)
+	}
+	s := &spool{path: path, dir: cleanSpoolDir(dir), maxSize: maxSize, segmentSize: maxSize / 8}
+	if s.segmentSize == 0 {
+		s.segmentSize = maxSize
+	}
+
+	_spoolsMu.Lock()
+	for other := range _spools {
+		if other.dir == s.dir {
+			_spoolsMu.Unlock()
+
+			return nil, fmt.Errorf("spool dir %q is already used by %q", dir, other.path)
+		}
+	}
+	_spools[s] = struct{}{}
+	_spoolsMu.Unlock()
+
+	if err := s.recover(); err != nil {
+		s.closeFiles()
+		_spoolsMu.Lock()
+		delete(_spools, s)
+		_spoolsMu.Unlock()
+
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// cleanSpoolDir returns the absolute form of dir so that the spools of a
+// directory are found whatever the form of its path.
+func cleanSpoolDir(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+
+	return filepath.Clean(dir)
+}
+
+// spoolDirOf returns the directory of the spool set for the output path, it
+// is empty when there is none.
+func spoolDirOf(path string, opts *Options) string {
+	u, _, ok := lookupScheme(path)
+	if !ok {
+		return ""
+	}
+	if spool, ok := spoolOf(u, opts); ok && spool.Dir != "" {
+		return cleanSpoolDir(spool.Dir)
+	}
+
+	return ""
+}
+
+func (s *spool) segmentName(id uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", id, spoolSuffix))
+}
+
+// recover opens the segments left by a previous process from the saved offset
+// on, cutting each one at its first incomplete or corrupt record.
+func (s *spool) recover() error {
+	names, err := filepath.Glob(filepath.Join(s.dir, "*"+spoolSuffix))
+	if err != nil {
+		return fmt.Errorf("list spool segments: %w", err)
+	}
+	var ids []uint64
+	for _, name := range names {
+		id, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(name), spoolSuffix), 10, 64)
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	offsetID, offsetPos := s.readOffset()
+	for _, id := range ids {
+		if id < offsetID {
+			_ = os.Remove(s.segmentName(id))
+
+			continue
+		}
+		pos := int64(0)
+		if id == offsetID {
+			pos = offsetPos
+		}
+		if len(s.segments) == 0 {
+			s.readPos = pos
+		}
+		size, err := s.scan(id, pos)
+		if err != nil {
+			return err
+		}
+		s.segments = append(s.segments, spoolSegment{id: id, size: size})
+	}
+	if len(s.segments) == 0 {
+		s.readPos = 0
+		s.segments = []spoolSegment{{id: offsetID + 1}}
+	}
+	atomic.StoreInt64(&s.segmentCount, int64(len(s.segments)))
+
+	last := s.segments[len(s.segments)-1]
+	if s.writer, err = os.OpenFile(s.segmentName(last.id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+		return fmt.Errorf("open spool segment: %w", err)
+	}
+	if s.reader, err = os.Open(s.segmentName(s.segments[0].id)); err != nil {
+		return fmt.Errorf("open spool segment: %w", err)
+	}
+
+	return s.saveOffset()
+}
+
+// scan counts the records of segment id from pos on and truncates the
+// segment after the last valid one, returning its size.
+func (s *spool) scan(id uint64, pos int64) (int64, error) {
+	f, err := os.OpenFile(s.segmentName(id), os.O_RDWR, 0o644)
+	if err != nil {
+		return 0, fmt.Errorf("open spool segment: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("stat spool segment: %w", err)
+	}
+	if pos > info.Size() {
+		pos = info.Size()
+		s.readPos = pos
+	}
+	for pos < info.Size() {
+		data, err := readRecord(f, pos, info.Size())
+		if err != nil {
+			break
+		}
+		pos += spoolHeaderSize + int64(len(data))
+		s.entries++
+		s.bytes += int64(len(data))
+	}
+	if pos < info.Size() {
+		if err := f.Truncate(pos); err != nil {
+			return 0, fmt.Errorf("truncate spool segment: %w", err)
+		}
+	}
+
+	return pos, nil
+}
+
+// readRecord reads the record at pos of a segment of size bytes.
+func readRecord(f *os.File, pos, size int64) ([]byte, error) {
+	var header [spoolHeaderSize]byte
+	if _, err := f.ReadAt(header[:], pos); err != nil {
+		return nil, err //nolint: wrapcheck // keep the error of the file.
+	}
+	length := int64(binary.BigEndian.Uint32(header[:4]))
+	if pos+spoolHeaderSize+length > size {
+		return nil, io.ErrUnexpectedEOF
+	}
+	data := make([]byte, length)
+	if _, err := f.ReadAt(data, pos+spoolHeaderSize); err != nil {
+		return nil, err //nolint: wrapcheck // keep the error of the file.
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, errors.New("corrupt spool record")
+	}
+
+	return data, nil
+}
+
+func (s *spool) readOffset() (uint64, int64) {
+	data, err := os.ReadFile(filepath.Join(s.dir, spoolOffsetFile))
+	if err != nil {
+		return 0, 0
+	}
+	var id uint64
+	var pos int64
+	if _, err := fmt.Sscanf(string(data), "%d %d", &id, &pos); err != nil {
+		return 0, 0
+	}
+
+	return id, pos
+}
+
+// saveOffset replaces the offset file by renaming a new one over it, the file
+// and the directory are synced so that a crash leaves either the old or the
+// new offset.
+func (s *spool) saveOffset() error {
+	name := filepath.Join(s.dir, spoolOffsetFile)
+	data := fmt.Sprintf("%d %d\n", s.segments[0].id, s.readPos)
+	if err := writeFileSync(name+".tmp", []byte(data)); err != nil {
+		return fmt.Errorf("save spool offset: %w", err)
+	}
+	if err := os.Rename(name+".tmp", name); err != nil {
+		return fmt.Errorf("save spool offset: %w", err)
+	}
+	if err := syncDir(s.dir); err != nil {
+		return fmt.Errorf("save spool offset: %w", err)
+	}
+	s.savedPos, s.savedAt = s.readPos, time.Now()
+
+	return nil
+}
+
+// writeFileSync writes data to the file name and syncs it to disk.
+func writeFileSync(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err //nolint: wrapcheck // wrapped by the caller.
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err //nolint: wrapcheck // wrapped by the caller.
+}
+
+// syncDir syncs the entries of the directory dir, making renames durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err //nolint: wrapcheck // wrapped by the caller.
+	}
+	err = d.Sync()
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err //nolint: wrapcheck // wrapped by the caller.
+}
+
+func (s *spool) push(p []byte) error {
+	record := make([]byte, spoolHeaderSize+len(p))
+	binary.BigEndian.PutUint32(record[:4], uint32(len(p)))
+	binary.BigEndian.PutUint32(record[4:spoolHeaderSize], crc32.ChecksumIEEE(p))
+	copy(record[spoolHeaderSize:], p)
+
+	last := &s.segments[len(s.segments)-1]
+	if last.size > 0 && last.size+int64(len(record)) > s.segmentSize {
+		if err := s.nextSegment(); err != nil {
+			return err
+		}
+		last = &s.segments[len(s.segments)-1]
+	}
+	n, err := s.writer.Write(record)
+	last.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("write spool segment: %w", err)
+	}
+	atomic.AddInt64(&s.entries, 1)
+	atomic.AddInt64(&s.bytes, int64(len(p)))
+
+	return s.evict()
+}
+
+func (s *spool) nextSegment() error {
+	id := s.segments[len(s.segments)-1].id + 1
+	f, err := os.OpenFile(s.segmentName(id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open spool segment: %w", err)
+	}
+	_ = s.writer.Close()
+	s.writer = f
+	s.segments = append(s.segments, spoolSegment{id: id})
+	atomic.StoreInt64(&s.segmentCount, int64(len(s.segments)))
+
+	return nil
+}
+
+// evict drops the oldest segments while the spool is larger than its max
+// size, the segment being written is kept.
+func (s *spool) evict() error {
+	var total int64
+	for _, seg := range s.segments {
+		total += seg.size
+	}
+	for total > s.maxSize && len(s.segments) > 1 {
+		total -= s.segments[0].size
+		for s.readPos < s.segments[0].size {
+			data, err := readRecord(s.reader, s.readPos, s.segments[0].size)
+			if err != nil {
+				break
+			}
+			s.advance(len(data))
+			atomic.AddUint64(&_droppedEntries, 1)
+		}
+		if err := s.removeFirst(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// advance moves the read position past the oldest record of size bytes.
+func (s *spool) advance(size int) {
+	s.readPos += spoolHeaderSize + int64(size)
+	s.readID++
+	atomic.AddInt64(&s.entries, -1)
+	atomic.AddInt64(&s.bytes, -int64(size))
+}
+
+// removeFirst removes the oldest segment and starts reading the next one.
+func (s *spool) removeFirst() error {
+	_ = s.reader.Close()
+	_ = os.Remove(s.segmentName(s.segments[0].id))
+	s.segments = s.segments[1:]
+	atomic.StoreInt64(&s.segmentCount, int64(len(s.segments)))
+	s.readPos = 0
+
+	var err error
+	if s.reader, err = os.Open(s.segmentName(s.segments[0].id)); err != nil {
+		return fmt.Errorf("open spool segment: %w", err)
+	}
+
+	return s.saveOffset()
+}
+
+// peek returns the oldest records of the segment being read, a batch does not
+// span segments.
+func (s *spool) peek(max, maxBytes int) (uint64, [][]byte) {
+	for s.readPos >= s.segments[0].size {
+		if len(s.segments) == 1 || s.removeFirst() != nil {
+			// the counts may be off after skipping a corrupt segment.
+			atomic.StoreInt64(&s.entries, 0)
+			atomic.StoreInt64(&s.bytes, 0)
+
+			return s.readID, nil
+		}
+	}
+
+	var batch [][]byte
+	size := 0
+	for pos := s.readPos; pos < s.segments[0].size && len(batch) < max; {
+		data, err := readRecord(s.reader, pos, s.segments[0].size)
+		if err != nil {
+			if len(batch) == 0 {
+				// the rest of a corrupt segment is lost.
+				s.readPos = s.segments[0].size
+
+				return s.peek(max, maxBytes)
+			}
+
+			break
+		}
+		if size += len(data); maxBytes > 0 && len(batch) > 0 && size > maxBytes {
+			break
+		}
+		batch = append(batch, data)
+		pos += spoolHeaderSize + int64(len(data))
+	}
+
+	return s.readID, batch
+}
+
+func (s *spool) pop(next uint64) {
+	moved := false
+	for s.readID < next && s.readPos < s.segments[0].size {
+		data, err := readRecord(s.reader, s.readPos, s.segments[0].size)
+		if err != nil {
+			break
+		}
+		s.advance(len(data))
+		moved = true
+	}
+	if moved && time.Since(s.savedAt) >= spoolOffsetInterval {
+		_ = s.saveOffset()
+	}
+}
+
+func (s *spool) empty() bool {
+	return atomic.LoadInt64(&s.entries) == 0
+}
+
+func (s *spool) durable() bool { return true }
+
+func (s *spool) sync() error {
+	if err := s.writer.Sync(); err != nil {
+		return err //nolint: wrapcheck // keep the error of the file.
+	}
+
+	return s.saveUnsavedOffset()
+}
+
+func (s *spool) close() error {
+	_spoolsMu.Lock()
+	delete(_spools, s)
+	_spoolsMu.Unlock()
+
+	err := s.saveUnsavedOffset()
+	if closeErr := s.closeFiles(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// saveUnsavedOffset saves the offset when the read position moved since it
+// was last saved.
+func (s *spool) saveUnsavedOffset() error {
+	if s.readPos == s.savedPos {
+		return nil
+	}
+
+	return s.saveOffset()
+}
+
+func (s *spool) closeFiles() error {
+	var errs []error
+	for _, f := range []*os.File{s.writer, s.reader} {
+		if f == nil {
+			continue
+		}
+		if err := f.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	s.writer, s.reader = nil, nil
+	if len(errs) != 0 {
+		return fmt.Errorf("close spool: %v", errs)
+	}
+
+	return nil
+}
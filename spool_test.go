@@ -0,0 +1,129 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// drain pops every entry of s.
+func drain(s *spool) []string {
+	var entries []string
+	for {
+		next, batch := s.peek(2, 0)
+		if len(batch) == 0 {
+			return entries
+		}
+		for _, entry := range batch {
+			entries = append(entries, string(entry))
+		}
+		s.pop(next + uint64(len(batch)))
+	}
+}
+
+func Test_spool_Replay(t *testing.T) {
+	dir := t.TempDir()
+	s, err := openSpool("tcp://test", dir, 1024)
+	assert.Nil(t, err)
+	for _, entry := range []string{"a", "b", "c"} {
+		assert.Nil(t, s.push([]byte(entry)))
+	}
+	next, batch := s.peek(1, 0)
+	assert.Equal(t, [][]byte{[]byte("a")}, batch)
+	s.pop(next + 1)
+	s.pop(next + 1) // already popped
+	assert.Nil(t, s.close())
+
+	// a crash in the middle of a write leaves an incomplete record.
+	segment := s.segmentName(s.segments[len(s.segments)-1].id)
+	f, err := os.OpenFile(segment, os.O_WRONLY|os.O_APPEND, 0o644)
+	assert.Nil(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 9, 1})
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	s, err = openSpool("tcp://test", dir, 1024)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), s.entries)
+	assert.Nil(t, s.push([]byte("d")))
+	assert.Equal(t, []string{"b", "c", "d"}, drain(s))
+	assert.True(t, s.empty())
+	assert.Nil(t, s.close())
+}
+
+func Test_spool_Evict(t *testing.T) {
+	dir := t.TempDir()
+	// segments of 16 bytes hold a single record of 10 bytes.
+	s, err := openSpool("tcp://evict", dir, 128)
+	assert.Nil(t, err)
+	defer s.close()
+
+	dropped := DroppedEntries()
+	for _, entry := range []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "a", "b", "c", "d", "e", "f"} {
+		assert.Nil(t, s.push([]byte(entry+entry)))
+	}
+	assert.Equal(t, uint64(4), DroppedEntries()-dropped)
+	assert.Equal(t, int64(12), s.entries)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+spoolSuffix))
+	assert.Nil(t, err)
+	assert.Len(t, files, 12)
+	var stats []SpoolStat
+	for _, stat := range SpoolStats() {
+		if stat.Path == "tcp://evict" {
+			stats = append(stats, stat)
+		}
+	}
+	assert.Equal(t, []SpoolStat{{Path: "tcp://evict", Entries: 12, Bytes: 24, Segments: 12}}, stats)
+
+	entries := drain(s)
+	assert.Equal(t, "44", entries[0])
+	assert.Equal(t, "ff", entries[len(entries)-1])
+}
+
+func Test_spool_Offset(t *testing.T) {
+	dir := t.TempDir()
+	s, err := openSpool("tcp://test", dir, 1024)
+	assert.Nil(t, err)
+	for _, entry := range []string{"a", "b", "c"} {
+		assert.Nil(t, s.push([]byte(entry)))
+	}
+	saved := func() string {
+		data, err := os.ReadFile(filepath.Join(dir, spoolOffsetFile))
+		assert.Nil(t, err)
+
+		return string(data)
+	}
+	initial := saved()
+	next, _ := s.peek(1, 0)
+	s.pop(next + 1)
+	// the offset is not saved again for every delivered batch.
+	assert.Equal(t, initial, saved())
+	assert.Nil(t, s.sync())
+	assert.NotEqual(t, initial, saved())
+	assert.NoFileExists(t, filepath.Join(dir, spoolOffsetFile+".tmp"))
+
+	next, _ = s.peek(1, 0)
+	s.pop(next + 1)
+	assert.Nil(t, s.close())
+	s, err = openSpool("tcp://test", dir, 1024)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"c"}, drain(s))
+	assert.Nil(t, s.close())
+}
+
+func Test_validateSpools(t *testing.T) {
+	opts := NewOptions()
+	opts.OutputPaths = []string{"stdout", "tcp://127.0.0.1:5170"}
+	opts.Spools = map[string]SpoolOptions{
+		"tcp://127.0.0.1:5170": {Dir: t.TempDir()},
+		"stdout":               {Dir: t.TempDir()},
+		"tcp://127.0.0.1:5171": {Dir: t.TempDir()},
+	}
+	errs := validateSpools(opts)
+	assert.Len(t, errs, 2)
+	assert.EqualError(t, errs[0], `spool of "stdout" which is not a remote output path`)
+	assert.EqualError(t, errs[1], `spool of "tcp://127.0.0.1:5171" which is not a remote output path`)
+}
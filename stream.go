@@ -0,0 +1,211 @@
+package log
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+const (
+	kilobyte = 1024
+
+	defaultStreamBufferInKB = 4096
+
+	streamDialTimeout = 5 * time.Second
+)
+
+var errConnInterrupted = errors.New("connection interrupted by close")
+
+// nolint: gochecknoinits // register the stream output path schemes.
+func init() {
+	scheme := sinkScheme{open: openStreamSink, config: streamConfig, buffered: true}
+	_sinkSchemes["tcp"] = scheme
+	_sinkSchemes["tls"] = scheme
+}
+
+// StreamOptions configures the tcp://host:port and tls://host:port output
+// paths, which stream the encoded entries to a log aggregator, and the
+// buffering of the syslog output paths and of every remote output path
+// sending batches over HTTP. The entries are buffered in memory, or in a spool
+// when one is set for the path.
+type StreamOptions struct {
+	// BufferSizeInKB bounds the entries kept while the aggregator is not
+	// reachable, the oldest entries are dropped beyond it. 0 means 4096.
+	BufferSizeInKB int `json:"buffer-size-in-kb"   mapstructure:"buffer-size-in-kb"`
+	// FlushTimeoutInMS bounds how long Flush waits for the buffered entries
+	// to be delivered, 0 does not wait.
+	FlushTimeoutInMS int `json:"flush-timeout-in-ms" mapstructure:"flush-timeout-in-ms"`
+}
+
+func (o StreamOptions) validate() []error {
+	var errs []error
+	if o.BufferSizeInKB < 0 {
+		errs = append(errs, fmt.Errorf("negative stream buffer size in KB: %d", o.BufferSizeInKB))
+	}
+	if o.FlushTimeoutInMS < 0 {
+		errs = append(errs, fmt.Errorf("negative stream flush timeout: %d", o.FlushTimeoutInMS))
+	}
+
+	return errs
+}
+
+type streamSinkConfig struct {
+	stream StreamOptions
+	tls    TLSOptions
+}
+
+func streamConfig(opts *Options) interface{} {
+	cfg := streamSinkConfig{stream: opts.Stream, tls: opts.TLS}
+	if cfg.stream.BufferSizeInKB == 0 {
+		cfg.stream.BufferSizeInKB = defaultStreamBufferInKB
+	}
+
+	return cfg
+}
+
+func openStreamSink(u *url.URL, opts *Options) (*sink, error) {
+	if u.Host == "" {
+		return nil, errors.New("missing host of stream output path")
+	}
+	dialer := &net.Dialer{Timeout: streamDialTimeout}
+	dial := func() (net.Conn, error) {
+		return dialer.Dial("tcp", u.Host)
+	}
+	if u.Scheme == "tls" {
+		cfg, err := opts.TLS.config()
+		if err != nil {
+			return nil, err
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = u.Hostname()
+		}
+		dial = func() (net.Conn, error) {
+			return tls.DialWithDialer(dialer, "tcp", u.Host, cfg)
+		}
+	}
+	cfg, _ := streamConfig(opts).(streamSinkConfig)
+	buffer, err := openBuffer(u, opts, cfg.stream.BufferSizeInKB*kilobyte)
+	if err != nil {
+		return nil, err
+	}
+	conn := &connWriter{dial: dial}
+	w := newBufferedWriter(buffer, sendEach(conn.send), batchPolicy{entries: 1, interrupt: conn.interrupt},
+		time.Duration(cfg.stream.FlushTimeoutInMS)*time.Millisecond)
+
+	return &sink{WriteSyncer: w, close: func() error {
+		return multierr.Combine(w.Close(), conn.close())
+	}}, nil
+}
+
+// connWriter writes to a connection which is dialed on the first write and
+// again after a failed write or read.
+type connWriter struct {
+	dial func() (net.Conn, error)
+
+	mu          sync.Mutex
+	conn        net.Conn
+	interrupted bool
+}
+
+// connect returns the current connection, dialing it when there is none.
+func (c *connWriter) connect() (net.Conn, error) {
+	c.mu.Lock()
+	conn, interrupted := c.conn, c.interrupted
+	c.mu.Unlock()
+	if interrupted {
+		return nil, errConnInterrupted
+	}
+	if conn != nil {
+		return conn, nil
+	}
+
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.interrupted {
+		_ = conn.Close()
+
+		return nil, errConnInterrupted
+	}
+	c.conn = conn
+
+	return conn, nil
+}
+
+// reset closes conn, the next write dials a new connection.
+func (c *connWriter) reset(conn net.Conn) {
+	_ = conn.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == conn {
+		c.conn = nil
+	}
+}
+
+func (c *connWriter) send(p []byte) error {
+	conn, err := c.connect()
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(p); err != nil {
+		// the entry is sent again from its start over the next connection.
+		c.reset(conn)
+
+		return err //nolint: wrapcheck // keep the error of the connection.
+	}
+
+	return nil
+}
+
+// receive calls decode with the connection the last write went through, it
+// fails once timeout elapsed. The connection is closed when decode fails, so
+// that what is sent again goes through the next connection.
+func (c *connWriter) receive(timeout time.Duration, decode func(r io.Reader) error) error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return errors.New("connection closed before the response")
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	if err := decode(conn); err != nil {
+		c.reset(conn)
+
+		return err
+	}
+
+	return nil
+}
+
+// interrupt aborts the pending write or read and stops dialing, it is safe to
+// call while they run.
+func (c *connWriter) interrupt() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interrupted = true
+	if c.conn != nil {
+		_ = c.conn.SetDeadline(time.Now())
+	}
+}
+
+func (c *connWriter) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+
+	return err //nolint: wrapcheck // keep the error of the connection.
+}
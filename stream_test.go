@@ -0,0 +1,202 @@
+package log_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/pem"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/huanghe314/log"
+)
+
+// readLines accepts a connection on ln and returns its first n lines.
+func readLines(t *testing.T, ln net.Listener, n int) []string {
+	t.Helper()
+	conn, err := ln.Accept()
+	if !assert.Nil(t, err) {
+		return nil
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var lines []string
+	scanner := bufio.NewScanner(conn)
+	for len(lines) < n && scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	assert.Nil(t, scanner.Err())
+
+	return lines
+}
+
+func Test_Stream_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := ln.Addr().String()
+	// the aggregator is down while the first entries are written.
+	assert.Nil(t, ln.Close())
+
+	opts := log.NewOptions()
+	opts.Format = "json"
+	opts.OutputPaths = []string{"tcp://" + addr}
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	logger.Info("during outage")
+	logger.Info("still down")
+	time.Sleep(200 * time.Millisecond)
+
+	ln, err = net.Listen("tcp", addr)
+	assert.Nil(t, err)
+	defer ln.Close()
+	lines := make(chan []string)
+	go func() { lines <- readLines(t, ln, 3) }()
+
+	logger.Info("back again")
+	logger.Flush()
+	got := <-lines
+	assert.Len(t, got, 3)
+	assert.Contains(t, got[0], `"msg":"during outage"`)
+	assert.Contains(t, got[1], `"msg":"still down"`)
+	assert.Contains(t, got[2], `"msg":"back again"`)
+}
+
+func Test_Stream_TLS(t *testing.T) {
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", srv.TLS)
+	assert.Nil(t, err)
+	defer ln.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	assert.Nil(t, os.WriteFile(caFile, data, 0o600))
+
+	opts := log.NewOptions()
+	opts.Format = "json"
+	opts.OutputPaths = []string{"tls://" + ln.Addr().String()}
+	opts.TLS.CAFile = caFile
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	lines := make(chan []string)
+	go func() { lines <- readLines(t, ln, 1) }()
+	logger.Infow("secure", "foo", "bar")
+	logger.Flush()
+	got := <-lines
+	assert.Len(t, got, 1)
+	assert.Contains(t, got[0], `"msg":"secure","foo":"bar"`)
+}
+
+func Test_Stream_Buffer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := ln.Addr().String()
+	assert.Nil(t, ln.Close())
+
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{"tcp://" + addr}
+	opts.Stream.BufferSizeInKB = 1
+	opts.Stream.FlushTimeoutInMS = 50
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+
+	dropped := log.DroppedEntries()
+	for i := 0; i < 100; i++ {
+		logger.Infof("entry %d", i)
+	}
+	assert.Greater(t, log.DroppedEntries(), dropped)
+	assert.NotNil(t, closeFunc(), "flush must time out while the aggregator is down")
+
+	opts.Stream.BufferSizeInKB = -1
+	opts.TLS.CertFile = "client.pem"
+	assert.Len(t, opts.Validate(), 2)
+}
+
+func Test_Stream_Spool(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := ln.Addr().String()
+	assert.Nil(t, ln.Close())
+
+	path := "tcp://" + addr
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{path}
+	opts.Stream.FlushTimeoutInMS = 50
+	opts.Spools = map[string]log.SpoolOptions{path: {Dir: t.TempDir()}}
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	logger.Info("spooled 1")
+	logger.Info("spooled 2")
+	stats := log.SpoolStats()
+	assert.Len(t, stats, 1)
+	assert.Equal(t, path, stats[0].Path)
+	assert.Equal(t, int64(2), stats[0].Entries)
+	// the spool keeps the entries which could not be delivered.
+	assert.Nil(t, closeFunc())
+
+	ln, err = net.Listen("tcp", addr)
+	assert.Nil(t, err)
+	defer ln.Close()
+	lines := make(chan []string)
+	go func() { lines <- readLines(t, ln, 3) }()
+
+	logger, closeFunc, err = log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+	logger.Info("live")
+	logger.Flush()
+	got := <-lines
+	assert.Len(t, got, 3)
+	assert.Contains(t, got[0], "spooled 1")
+	assert.Contains(t, got[1], "spooled 2")
+	assert.Contains(t, got[2], "live")
+	assert.Equal(t, int64(0), log.SpoolStats()[0].Entries)
+
+	opts.Spools[path] = log.SpoolOptions{}
+	assert.Len(t, opts.Validate(), 1)
+}
+
+func Test_Stream_SpoolReload(t *testing.T) {
+	defer log.Init(log.NewOptions())
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := ln.Addr().String()
+	assert.Nil(t, ln.Close())
+
+	path := "tcp://" + addr
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{path}
+	opts.Stream.FlushTimeoutInMS = 50
+	opts.Spools = map[string]log.SpoolOptions{path: {Dir: t.TempDir()}}
+	log.Init(opts)
+	log.Info("before reload")
+
+	// a second spool on the same directory is refused.
+	_, _, err = log.New(opts)
+	assert.NotNil(t, err)
+
+	// the spool is handed over to the sink opened with the new options.
+	next := *opts
+	next.Stream.BufferSizeInKB = 1024
+	assert.Nil(t, log.ReloadOptions(&next))
+	log.Info("after reload")
+
+	var entries []int64
+	for _, stat := range log.SpoolStats() {
+		if stat.Path == path {
+			entries = append(entries, stat.Entries)
+		}
+	}
+	// the entries include the one about the reload.
+	assert.Equal(t, []int64{3}, entries)
+}
@@ -0,0 +1,329 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	rfc5424 = "rfc5424"
+	rfc3164 = "rfc3164"
+
+	syslogDialTimeout = 5 * time.Second
+)
+
+// nolint: gochecknoinits // register the syslog output path schemes.
+func init() {
+	scheme := sinkScheme{open: openSyslogSink, config: syslogConfig, buffered: true}
+	_sinkSchemes["syslog"] = scheme
+	_sinkSchemes["syslog+udp"] = scheme
+	_sinkSchemes["syslog+tcp"] = scheme
+}
+
+var _syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// SyslogOptions configures the syslog output paths: syslog:///dev/log,
+// syslog+udp://host:514 and syslog+tcp://host:601. syslog:// without a path
+// uses the local syslog daemon.
+type SyslogOptions struct {
+	// Facility defaults to user.
+	Facility string `json:"facility" mapstructure:"facility"`
+	// AppName defaults to Options.Name, or to the program name when both are empty.
+	AppName string `json:"app-name" mapstructure:"app-name"`
+	// Format is the message format, rfc5424 (default) or rfc3164.
+	Format string `json:"format"   mapstructure:"format"`
+}
+
+func (o SyslogOptions) validate() []error {
+	var errs []error
+	if _, ok := _syslogFacilities[o.Facility]; !ok && o.Facility != "" {
+		errs = append(errs, fmt.Errorf("not a valid syslog facility: %q", o.Facility))
+	}
+	if o.Format != "" && o.Format != rfc5424 && o.Format != rfc3164 {
+		errs = append(errs, fmt.Errorf("not a valid syslog format: %q", o.Format))
+	}
+
+	return errs
+}
+
+type syslogSinkConfig struct {
+	syslog SyslogOptions
+	stream StreamOptions
+}
+
+func syslogConfig(opts *Options) interface{} {
+	cfg := opts.Syslog
+	if cfg.Facility == "" {
+		cfg.Facility = "user"
+	}
+	if cfg.Format == "" {
+		cfg.Format = rfc5424
+	}
+	if cfg.AppName == "" {
+		cfg.AppName = opts.Name
+	}
+	if cfg.AppName == "" && len(os.Args) > 0 {
+		cfg.AppName = filepath.Base(os.Args[0])
+	}
+	stream := opts.Stream
+	if stream.BufferSizeInKB == 0 {
+		stream.BufferSizeInKB = defaultStreamBufferInKB
+	}
+
+	return syslogSinkConfig{syslog: cfg, stream: stream}
+}
+
+// syslogSeverity maps zap levels to syslog severities.
+func syslogSeverity(lvl zapcore.Level) int {
+	switch lvl {
+	case zapcore.DebugLevel:
+		return 7 // debug
+	case zapcore.InfoLevel:
+		return 6 // info
+	case zapcore.WarnLevel:
+		return 4 // warning
+	case zapcore.ErrorLevel:
+		return 3 // err
+	case zapcore.DPanicLevel:
+		return 2 // crit
+	case zapcore.PanicLevel:
+		return 1 // alert
+	case zapcore.FatalLevel:
+		return 0 // emerg
+	default:
+		return 6
+	}
+}
+
+// syslogWriter sends messages to a syslog server, reconnecting once when a
+// send fails. The messages are buffered so that logging never waits for the
+// server.
+type syslogWriter struct {
+	network  string
+	addrs    []string
+	rfc5424  bool
+	facility int
+	appName  string
+	hostname string
+	pid      int
+
+	// buffered holds the messages in memory, or in a spool when one is set
+	// for the path, until they are sent.
+	buffered *bufferedWriter
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func openSyslogSink(u *url.URL, opts *Options) (*sink, error) {
+	cfg, _ := syslogConfig(opts).(syslogSinkConfig)
+	w := &syslogWriter{
+		rfc5424:  cfg.syslog.Format == rfc5424,
+		facility: _syslogFacilities[cfg.syslog.Facility],
+		appName:  cfg.syslog.AppName,
+		pid:      os.Getpid(),
+	}
+	w.hostname, _ = os.Hostname()
+
+	switch u.Scheme {
+	case "syslog+udp":
+		w.network, w.addrs = "udp", []string{u.Host}
+	case "syslog+tcp":
+		w.network, w.addrs = "tcp", []string{u.Host}
+	default:
+		w.network, w.addrs = "unix", []string{u.Path}
+		if u.Path == "" {
+			w.addrs = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+		}
+	}
+
+	w.mu.Lock()
+	err := w.connect()
+	w.mu.Unlock()
+	// a spooled sink keeps the messages until the server is reachable.
+	if _, ok := spoolOf(u, opts); err != nil && !ok {
+		return nil, err
+	}
+	buffer, err := openBuffer(u, opts, cfg.stream.BufferSizeInKB*kilobyte)
+	if err != nil {
+		_ = w.Close()
+
+		return nil, err
+	}
+	w.buffered = newBufferedWriter(buffer, sendEach(w.send), batchPolicy{entries: 1},
+		time.Duration(cfg.stream.FlushTimeoutInMS)*time.Millisecond)
+
+	// the time and the level are in the header of the messages, MSG holds the
+	// rest of the entry.
+	msgConfig := encoderConfigFromOpts(opts)
+	msgConfig.TimeKey, msgConfig.LevelKey = "", ""
+	msgEncoder := buildEncoder(zap.Config{Encoding: opts.Format, EncoderConfig: msgConfig})
+
+	return &sink{
+		WriteSyncer: w,
+		close:       w.Close,
+		newCore: func(_ zapcore.Encoder, enabler zapcore.LevelEnabler) zapcore.Core {
+			enc := msgEncoder.Clone()
+
+			return &entryCore{
+				LevelEnabler: enabler,
+				write: func(ent zapcore.Entry, fields []zapcore.Field) error {
+					buf, err := enc.EncodeEntry(ent, fields)
+					if err != nil {
+						return err //nolint: wrapcheck // keep the error of the encoder.
+					}
+					defer buf.Free()
+
+					return w.writeMessage(ent.Level, ent.Time, buf.Bytes())
+				},
+				sync: w.Sync,
+			}
+		},
+	}, nil
+}
+
+// connect dials the syslog server, the caller must hold mu.
+func (w *syslogWriter) connect() error {
+	var errs []error
+	for _, addr := range w.addrs {
+		networks := []string{w.network}
+		if w.network == "unix" {
+			networks = []string{"unixgram", "unix"}
+		}
+		for _, network := range networks {
+			conn, err := net.DialTimeout(network, addr, syslogDialTimeout)
+			if err == nil {
+				w.conn = conn
+
+				return nil
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	return fmt.Errorf("connect to syslog: %v", errs)
+}
+
+// Write sends p as an info message.
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	if err := w.writeMessage(zapcore.InfoLevel, time.Now(), p); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (w *syslogWriter) writeMessage(lvl zapcore.Level, t time.Time, msg []byte) error {
+	_, err := w.buffered.Write(w.format(lvl, t, bytes.TrimRight(msg, "\n")))
+
+	return err
+}
+
+// send writes message to the server, dialing it again when the write fails.
+// It is framed for the connection it goes through.
+func (w *syslogWriter) send(message []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn != nil {
+		if _, err := w.conn.Write(w.frame(message)); err == nil {
+			return nil
+		}
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+	if err := w.connect(); err != nil {
+		return err
+	}
+	if _, err := w.conn.Write(w.frame(message)); err != nil {
+		return fmt.Errorf("write to syslog: %w", err)
+	}
+
+	return nil
+}
+
+// format formats msg as a syslog message.
+func (w *syslogWriter) format(lvl zapcore.Level, t time.Time, msg []byte) []byte {
+	pri := w.facility*8 + syslogSeverity(lvl)
+
+	var b bytes.Buffer
+	if w.rfc5424 {
+		// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+		fmt.Fprintf(&b, "<%d>1 %s %s %s %d - - ",
+			pri, t.Format("2006-01-02T15:04:05.000000Z07:00"), nilValue(w.hostname), nilValue(w.appName), w.pid)
+	} else {
+		// <PRI>TIMESTAMP HOSTNAME TAG[PID]: MSG, local daemons add the hostname.
+		fmt.Fprintf(&b, "<%d>%s ", pri, t.Format(time.Stamp))
+		if w.network != "unix" {
+			fmt.Fprintf(&b, "%s ", w.hostname)
+		}
+		fmt.Fprintf(&b, "%s[%d]: ", w.appName, w.pid)
+	}
+	b.Write(msg)
+
+	return b.Bytes()
+}
+
+// frame frames message for the current connection, the caller must hold mu.
+// Messages sent over streams are framed by octet counting for RFC 5424 and by
+// a trailing newline for RFC 3164, datagrams are not framed.
+func (w *syslogWriter) frame(message []byte) []byte {
+	switch w.conn.LocalAddr().Network() {
+	case "tcp", "unix":
+	default:
+		return message
+	}
+	if !w.rfc5424 {
+		return append(message[:len(message):len(message)], '\n')
+	}
+
+	return append([]byte(strconv.Itoa(len(message))+" "), message...)
+}
+
+func nilValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+func (w *syslogWriter) Sync() error {
+	return w.buffered.Sync()
+}
+
+func (w *syslogWriter) Close() error {
+	if w.buffered != nil {
+		if err := w.buffered.Close(); err != nil {
+			return err
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		return fmt.Errorf("close syslog: %w", err)
+	}
+
+	return nil
+}
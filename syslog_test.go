@@ -0,0 +1,159 @@
+package log_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/huanghe314/log"
+)
+
+func readPacket(t *testing.T, conn net.PacketConn) string {
+	t.Helper()
+	buf := make([]byte, 64*1024)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	assert.Nil(t, err)
+
+	return string(buf[:n])
+}
+
+func Test_Syslog_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	opts := log.NewOptions()
+	opts.Name = "app"
+	opts.Syslog.Facility = "local0"
+	opts.OutputPaths = []string{"syslog+udp://" + conn.LocalAddr().String()}
+	opts.ErrorOutputPaths = opts.OutputPaths
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	logger.Infow("hello", "foo", "bar")
+	msg := readPacket(t, conn)
+	assert.True(t, strings.HasPrefix(msg, "<134>1 "), msg) // local0.info
+	// the time and the level are only in the header.
+	assert.True(t, strings.HasSuffix(msg, " app "+strconv.Itoa(os.Getpid())+` - - hello	{"foo": "bar"}`), msg)
+
+	logger.Error("failed")
+	assert.True(t, strings.HasPrefix(readPacket(t, conn), "<131>1 ")) // local0.err
+}
+
+func Test_Syslog_Unix(t *testing.T) {
+	// unix socket paths are limited in length, t.TempDir may be too long.
+	dir, err := os.MkdirTemp("", "syslog")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log.sock")
+	conn, err := net.ListenPacket("unixgram", path)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	opts := log.NewOptions()
+	opts.Syslog.AppName = "daemon"
+	opts.Syslog.Format = "rfc3164"
+	opts.OutputPaths = []string{"syslog://" + path}
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	logger.Warn("careful")
+	msg := readPacket(t, conn)
+	assert.True(t, strings.HasPrefix(msg, "<12>"), msg) // user.warning
+	assert.Contains(t, msg, " daemon["+strconv.Itoa(os.Getpid())+"]: ")
+	assert.Contains(t, msg, "careful")
+}
+
+func Test_Syslog_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+
+	opts := log.NewOptions()
+	opts.Name = "app"
+	opts.OutputPaths = []string{"syslog+tcp://" + ln.Addr().String()}
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	conn, err := ln.Accept()
+	assert.Nil(t, err)
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	logger.Info("first")
+	logger.Info("second")
+	r := bufio.NewReader(conn)
+	for _, want := range []string{"first", "second"} {
+		// RFC 6587 octet counting: MSG-LEN SP SYSLOG-MSG
+		length, err := r.ReadString(' ')
+		assert.Nil(t, err)
+		n, err := strconv.Atoi(strings.TrimSpace(length))
+		assert.Nil(t, err)
+		msg := make([]byte, n)
+		_, err = io.ReadFull(r, msg)
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(string(msg), "<14>1 "))
+		assert.True(t, strings.HasSuffix(string(msg), want), string(msg))
+	}
+}
+
+func Test_Syslog_Reconnect(t *testing.T) {
+	// unix socket paths are limited in length, t.TempDir may be too long.
+	dir, err := os.MkdirTemp("", "syslog")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log.sock")
+	conn, err := net.ListenPacket("unixgram", path)
+	assert.Nil(t, err)
+
+	opts := log.NewOptions()
+	opts.OutputPaths = []string{"syslog://" + path}
+	logger, closeFunc, err := log.New(opts)
+	assert.Nil(t, err)
+	defer closeFunc()
+
+	logger.Info("datagram")
+	assert.True(t, strings.HasPrefix(readPacket(t, conn), "<14>1 "))
+
+	// the daemon comes back listening on a stream socket, the messages are
+	// then framed by octet counting.
+	conn.Close()
+	os.Remove(path)
+	ln, err := net.Listen("unix", path)
+	assert.Nil(t, err)
+	defer ln.Close()
+
+	logger.Info("stream")
+	stream, err := ln.Accept()
+	assert.Nil(t, err)
+	defer stream.Close()
+	_ = stream.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(stream)
+	length, err := r.ReadString(' ')
+	assert.Nil(t, err)
+	n, err := strconv.Atoi(strings.TrimSpace(length))
+	assert.Nil(t, err)
+	msg := make([]byte, n)
+	_, err = io.ReadFull(r, msg)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasSuffix(string(msg), " - - stream"), string(msg))
+}
+
+func Test_Syslog_Options(t *testing.T) {
+	opts := log.NewOptions()
+	opts.Syslog.Facility = "local9"
+	opts.Syslog.Format = "rfc1234"
+	assert.Len(t, opts.Validate(), 2)
+}
@@ -0,0 +1,57 @@
+package log
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSOptions configures the TLS connections of remote output paths.
+type TLSOptions struct {
+	// CAFile holds the PEM encoded CAs verifying the server, the system pool
+	// is used when empty.
+	CAFile string `json:"ca-file"              mapstructure:"ca-file"`
+	// CertFile and KeyFile hold the PEM encoded client certificate and key.
+	CertFile           string `json:"cert-file"            mapstructure:"cert-file"`
+	KeyFile            string `json:"key-file"             mapstructure:"key-file"`
+	ServerName         string `json:"server-name"          mapstructure:"server-name"`
+	InsecureSkipVerify bool   `json:"insecure-skip-verify" mapstructure:"insecure-skip-verify"`
+}
+
+func (o TLSOptions) validate() []error {
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return []error{errors.New("tls cert file and key file must be set together")}
+	}
+
+	return nil
+}
+
+// config builds the tls.Config of the options.
+func (o TLSOptions) config() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify, //nolint: gosec // set on purpose by the user.
+		MinVersion:         tls.VersionTLS12,
+	}
+	if o.CAFile != "" {
+		data, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read tls ca file: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificate found in tls ca file %q", o.CAFile)
+		}
+	}
+	if o.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load tls client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}